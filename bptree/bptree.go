@@ -1,10 +1,10 @@
 package bptree
 
 import (
+	"cmp"
 	"container/list"
-	"errors"
 	"fmt"
-	"reflect"
+	"sync"
 )
 
 /*
@@ -14,21 +14,24 @@ import (
 * 对于所有内部节点，子指针的数目总是比元素的数目多一个。所有叶子都在相同的高度上，叶结点本身按关键字大小从小到大链接。
  */
 
+// 默认配置 每棵树可以通过WithOrder单独设置阶数
 const (
-	ORDER     = 5         // 树的阶数:每个节点最大数据量=阶数-1
-	MAX_LIMIT = ORDER - 1 // 节点的key数量上限
-	MIN_LIMIT = ORDER / 2 // 节点的key数量下限
+	ORDER     = 5               // 树的阶数:每个节点最大数据量=阶数-1
+	MAX_LIMIT = ORDER - 1       // 节点的key数量上限
+	MIN_LIMIT = (ORDER - 1) / 2 // 节点的key数量下限(偶数阶时保证非叶子节点分裂后两边都合法)
 )
 
-var (
-	err        error
-	splitIndex = 0        // 节点分裂的索引值(由order计算而得)
-	queue      *list.List // 遍历Tree队列(链表) 只用于print调试
-)
+// Tree :B+树 K为key类型 V为value类型
+type Tree[K any, V any] struct {
+	Root    *Node[K, V]
+	compare func(a, b K) int // key比较函数 a<b返回负数 a==b返回0 a>b返回正数
+	treeLimits
+
+	concurrent bool         // 是否开启并发模式(latch crabbing)
+	rootLatch  sync.RWMutex // 并发模式下保护Root指针
 
-// Tree :B+树
-type Tree struct {
-	Root *Node
+	gen      uint64       // 当前版本 每次Snapshot加1 版本更小的节点被快照共享 修改前需要复制
+	cowLatch sync.RWMutex // 并发模式下 复制共享节点的操作独占整棵树
 }
 
 // Node :树节点
@@ -36,32 +39,43 @@ type Tree struct {
 *非叶子节点Pointers指向Node: len(Key) == len(Pointers)-1
 *叶子节点Pointers指向数据Record: len(Key) == len(Pointers)
  */
-type Node struct {
-	Keys     []int         // 索引切片
+type Node[K any, V any] struct {
+	Keys     []K           // 索引切片
 	Pointers []interface{} // 指针切片 非叶子节点指向下一个节点 叶子节点指向数据Record
-	Parent   *Node         // 父节点
+	Parent   *Node[K, V]   // 父节点
 	IsLeaf   bool          // 是否叶子节点
 	Count    int           // 当前节点的key数量
-	Next     *Node         // 叶子节点双向链表 下一节点指针
-	Prev     *Node         // 叶子节点双向链表 上一节点指针
+	Next     *Node[K, V]   // 叶子节点双向链表 下一节点指针
+	Prev     *Node[K, V]   // 叶子节点双向链表 上一节点指针
+
+	latch sync.RWMutex // 并发模式下的节点读写latch
+	gen   uint64       // 创建时tree的版本
+	size  int64        // 子树中的数据数量 原子读写(见rank.go)
 }
 
 // Record :数据记录
-type Record struct {
-	Value []byte
+type Record[V any] struct {
+	Value V
 }
 
-// NewTree :构造函数 同时初始化分裂节点的index
-func NewTree() *Tree {
-	// 设置节点分裂的index(只与order相关)
-	length := ORDER - 1
-	if length%2 == 0 {
-		splitIndex = length / 2
-	} else {
-		splitIndex = length/2 + 1
+// NewTree :构造函数 key为可排序类型(cmp.Ordered) 按自然顺序比较 阶数或填充比例无效时panic
+func NewTree[K cmp.Ordered, V any](opts ...Option) *Tree[K, V] {
+	return NewTreeFunc[K, V](cmp.Compare[K], opts...)
+}
+
+// NewTreeFunc :构造函数 使用自定义的key比较函数 同时根据配置初始化阶数和分裂节点的index
+/*
+WithOrder小于3或者WithFillFactor不在(0,1]时panic,panic的值是包装了ErrInvalidOrder/ErrInvalidArgument的error。
+*/
+func NewTreeFunc[K any, V any](compare func(a, b K) int, opts ...Option) *Tree[K, V] {
+	c := defaultConfig()
+	for _, opt := range opts {
+		opt(&c)
 	}
 
-	return &Tree{}
+	t := &Tree[K, V]{compare: compare, concurrent: c.concurrent}
+	c.apply(&t.treeLimits)
+	return t
 }
 
 // *********************** Insert部分 ***********************
@@ -73,24 +87,41 @@ func NewTree() *Tree {
 为了使它工作，元素的最小和最大数目典型的必须选择为使最小数不小于最大数的一半。
 */
 
-// Insert :插入key
-func (t *Tree) Insert(key int, value []byte) error {
-	// Find没有err 说明key查找有返回 重复Insert
-	if _, err := t.Find(key); err == nil {
-		return errors.New("key already exists")
-	}
-
+// Insert :插入key key已经存在时返回错误
+func (t *Tree[K, V]) Insert(key K, value V) error {
 	pointer, err := newRecord(value)
 	if err != nil {
 		return err
 	}
+	if t.concurrent {
+		return t.concurrentInsert(key, pointer, false)
+	}
+	return t.insert(key, pointer, false)
+}
+
+// replace为true时key已经存在就替换Record(Put) 否则返回错误
+func (t *Tree[K, V]) insert(key K, pointer *Record[V], replace bool) error {
+	// key已经在叶子节点中 重复Insert(并发模式下也会走到这里 所以不调用Find)
+	leaf := t.findLeaf(key)
+	if leaf != nil {
+		if i := leaf.getKeyIndex(key, t.compare); i > -1 {
+			if !replace {
+				return keyError(ErrKeyExists, key)
+			}
+			t.writable(leaf).Pointers[i] = pointer
+			return nil
+		}
+	}
 
 	if t.Root == nil {
 		return t.initRoot(key, pointer)
 	}
 
-	leaf := t.findLeaf(key)
-	if leaf.Count < MAX_LIMIT {
+	// 数量先加到叶子节点和所有祖先上 分裂时再按子节点重新计算
+	leaf = t.writable(leaf)
+	t.resize(leaf, 1)
+
+	if leaf.Count < t.maxLimit {
 		// 当前节点未排满 直接insert
 		return t.insertIntoNode(leaf, key, pointer)
 	}
@@ -99,9 +130,10 @@ func (t *Tree) Insert(key int, value []byte) error {
 }
 
 // 正常insert 直接插入一个数据至节点中
-func (t *Tree) insertIntoNode(n *Node, key int, pointer interface{}) error {
+func (t *Tree[K, V]) insertIntoNode(n *Node[K, V], key K, pointer interface{}) error {
+	n = t.writable(n)
 	// 1.插入新记录
-	newKeys, newPointers := setIntoNode(*n, key, pointer)
+	newKeys, newPointers := setIntoNode(n, key, pointer, t.compare)
 	n.Keys = newKeys
 	n.Pointers = newPointers
 	// 2.节点长度+1
@@ -110,30 +142,38 @@ func (t *Tree) insertIntoNode(n *Node, key int, pointer interface{}) error {
 }
 
 // 分裂 然后insert至叶子节点
-func (t *Tree) splitAndInsertIntoLeaf(leaf *Node, key int, pointer *Record) error {
+func (t *Tree[K, V]) splitAndInsertIntoLeaf(leaf *Node[K, V], key K, pointer *Record[V]) error {
+	leaf = t.writable(leaf)
 	// 创建新Leaf
-	newLeaf, _ := newLeaf()
+	newLeaf, _ := newLeaf[K, V]()
+	newLeaf.gen = t.gen
 	// 1.临时插入后的keys和pointers
-	tempKeys, tempPointers := setIntoNode(*leaf, key, pointer)
+	tempKeys, tempPointers := setIntoNode(leaf, key, pointer, t.compare)
 
 	// 插入后的左节点(原节点)
-	leaf.Keys = tempKeys[0:splitIndex]
-	leaf.Pointers = tempPointers[0:splitIndex]
+	leaf.Keys = tempKeys[0:t.leafSplit:t.leafSplit] // 限制cap 避免左节点append时覆盖右节点的数据
+	leaf.Pointers = tempPointers[0:t.leafSplit:t.leafSplit]
 	leaf.Count = len(leaf.Keys)
 	// 插入后的右节点
-	newLeaf.Keys = tempKeys[splitIndex:]
-	newLeaf.Pointers = tempPointers[splitIndex:]
+	newLeaf.Keys = tempKeys[t.leafSplit:]
+	newLeaf.Pointers = tempPointers[t.leafSplit:]
 	newLeaf.Count = len(newLeaf.Keys)
 	newLeaf.Parent = leaf.Parent
 	// 双向链表处理（链表指针都是分裂或者合并而来）
+	newLeaf.Next = leaf.Next
+	if leaf.Next != nil {
+		leaf.Next.Prev = newLeaf
+	}
 	leaf.Next = newLeaf
 	newLeaf.Prev = leaf
+	leaf.recount()
+	newLeaf.recount()
 
-	return t.insertIntoParent(leaf, tempKeys[splitIndex], newLeaf)
+	return t.insertIntoParent(leaf, tempKeys[t.leafSplit], newLeaf)
 }
 
 // 分裂后 插入父节点
-func (t *Tree) insertIntoParent(left *Node, key int, right *Node) error {
+func (t *Tree[K, V]) insertIntoParent(left *Node[K, V], key K, right *Node[K, V]) error {
 	parent := left.Parent
 
 	// 父节点为空 说明是根节点分裂 则拉高为新的根节点 结束
@@ -142,7 +182,7 @@ func (t *Tree) insertIntoParent(left *Node, key int, right *Node) error {
 	}
 
 	// 父节点未填满 则直接塞入
-	if parent.Count < MAX_LIMIT {
+	if parent.Count < t.maxLimit {
 		t.insertIntoNode(parent, key, right)
 		return nil
 	}
@@ -152,32 +192,42 @@ func (t *Tree) insertIntoParent(left *Node, key int, right *Node) error {
 }
 
 // 分裂 然后insert至非叶子节点
-func (t *Tree) splitAndInsertIntoNode(n *Node, key int, pointer *Node) error {
+func (t *Tree[K, V]) splitAndInsertIntoNode(n *Node[K, V], key K, pointer *Node[K, V]) error {
+	n = t.writable(n)
 	// 创建新Node
-	newNode, _ := newNode()
+	newNode, _ := newNode[K, V]()
+	newNode.gen = t.gen
 	// 1.临时插入后的keys和pointers
-	tempKeys, tempPointers := setIntoNode(*n, key, pointer)
+	tempKeys, tempPointers := setIntoNode(n, key, pointer, t.compare)
 
 	// 插入后的左节点(原节点)
-	n.Keys = tempKeys[0:splitIndex]
-	n.Pointers = tempPointers[0 : splitIndex+1] //! 非叶子节点points数量比keys多1
+	n.Keys = tempKeys[0:t.nodeSplit:t.nodeSplit]
+	n.Pointers = tempPointers[0 : t.nodeSplit+1 : t.nodeSplit+1] //! 非叶子节点points数量比keys多1
 	n.Count = len(n.Keys)
 	// 插入后的右节点
-	newNode.Keys = tempKeys[splitIndex+1:] // 这里分裂非叶节点 往parent插入的key 子节点不保留
-	newNode.Pointers = tempPointers[splitIndex+1:]
+	newNode.Keys = tempKeys[t.nodeSplit+1:] // 这里分裂非叶节点 往parent插入的key 子节点不保留
+	newNode.Pointers = tempPointers[t.nodeSplit+1:]
 	newNode.Count = len(newNode.Keys)
 	newNode.Parent = n.Parent
+	// 移到右节点的子节点 父节点指针也要更新
+	for _, p := range newNode.Pointers {
+		p.(*Node[K, V]).Parent = newNode
+	}
+	n.recount()
+	newNode.recount()
 
-	return t.insertIntoParent(n, tempKeys[splitIndex], newNode)
+	return t.insertIntoParent(n, tempKeys[t.nodeSplit], newNode)
 }
 
 // 根节点分裂：需要创建一个新root
-func (t *Tree) insertIntoNewRoot(left *Node, key int, right *Node) error {
-	t.Root, err = newNode()
+func (t *Tree[K, V]) insertIntoNewRoot(left *Node[K, V], key K, right *Node[K, V]) error {
+	root, err := newNode[K, V]()
 	if err != nil {
 		return err
 	}
+	root.gen = t.gen
 
+	t.Root = root
 	t.Root.Keys = append(t.Root.Keys, key)
 	t.Root.Pointers = append(t.Root.Pointers, left)
 	t.Root.Pointers = append(t.Root.Pointers, right)
@@ -185,48 +235,52 @@ func (t *Tree) insertIntoNewRoot(left *Node, key int, right *Node) error {
 	t.Root.Parent = nil
 	left.Parent = t.Root
 	right.Parent = t.Root
+	t.Root.recount()
 	return nil
 }
 
 // 初始化根节点
-func (t *Tree) initRoot(key int, pointer *Record) error {
-	t.Root, err = newLeaf()
+func (t *Tree[K, V]) initRoot(key K, pointer *Record[V]) error {
+	root, err := newLeaf[K, V]()
 	if err != nil {
 		return err
 	}
+	root.gen = t.gen
+
+	t.Root = root
 	t.Root.Keys = append(t.Root.Keys, key)
 	t.Root.Pointers = append(t.Root.Pointers, pointer)
-	// t.Root.Pointers[MAX_LIMIT] = nil
 	t.Root.Parent = nil
 	t.Root.Count++
+	t.Root.size = 1
 	return nil
 }
 
 // 获取某个key在node中应该插入的位置
-func (n *Node) getInsertIndex(key int) (i int) {
-	for i < n.Count && n.Keys[i] < key {
+func (n *Node[K, V]) getInsertIndex(key K, compare func(a, b K) int) (i int) {
+	for i < n.Count && compare(n.Keys[i], key) < 0 {
 		i++
 	}
 	return
 }
 
 // 获取叶子节点某key的Record
-func (n *Node) getRecord(key int) *Record {
+func (n *Node[K, V]) getRecord(key K, compare func(a, b K) int) *Record[V] {
 	if !n.IsLeaf {
 		return nil
 	}
 
-	i := n.getKeyIndex(key)
+	i := n.getKeyIndex(key, compare)
 	if i > -1 {
-		return n.Pointers[i].(*Record)
+		return n.Pointers[i].(*Record[V])
 	}
 	return nil
 }
 
 // 获取节点某key的index
-func (n *Node) getKeyIndex(key int) int {
+func (n *Node[K, V]) getKeyIndex(key K, compare func(a, b K) int) int {
 	for i, k := range n.Keys {
-		if k == key {
+		if compare(k, key) == 0 {
 			return i
 		}
 	}
@@ -234,7 +288,7 @@ func (n *Node) getKeyIndex(key int) int {
 }
 
 // 获取节点某pointer的index
-func (n *Node) getPointerIndex(pointer interface{}) int {
+func (n *Node[K, V]) getPointerIndex(pointer interface{}) int {
 	for i, p := range n.Pointers {
 		if p == pointer {
 			return i
@@ -244,11 +298,11 @@ func (n *Node) getPointerIndex(pointer interface{}) int {
 }
 
 // 塞入节点 返回新的keys和pointers
-func setIntoNode(n Node, key int, pointer interface{}) ([]int, []interface{}) {
+func setIntoNode[K any, V any](n *Node[K, V], key K, pointer interface{}, compare func(a, b K) int) ([]K, []interface{}) {
 	// 1.找到插入点
-	i := n.getInsertIndex(key)
-	// 2.插入新记录 返回新keys和pointers(不改变原node数据,所以node不是指针)
-	keys := append([]int{}, n.Keys[0:i]...)
+	i := n.getInsertIndex(key, compare)
+	// 2.插入新记录 返回新keys和pointers(不改变原node数据,都是新建的切片)
+	keys := append([]K{}, n.Keys[0:i]...)
 	keys = append(keys, key)
 	keys = append(keys, n.Keys[i:]...)
 	// 非叶子节点 pointers的索引比keys多1
@@ -274,40 +328,50 @@ func setIntoNode(n Node, key int, pointer interface{}) ([]int, []interface{}) {
 */
 
 // Delete :删除key
-func (t *Tree) Delete(key int) error {
+func (t *Tree[K, V]) Delete(key K) error {
+	if t.concurrent {
+		return t.concurrentDelete(key)
+	}
+	return t.delete(key)
+}
+
+func (t *Tree[K, V]) delete(key K) error {
 	// keyRecord, err := t.Find(key)
 	// if err != nil {
 	// 	return err
 	// }
 	keyLeaf := t.findLeaf(key)
 	if keyLeaf != nil {
-		keyRecord := keyLeaf.getRecord(key)
+		keyRecord := keyLeaf.getRecord(key, t.compare)
 		if keyRecord != nil {
+			keyLeaf = t.writable(keyLeaf)
+			t.resize(keyLeaf, -1)
 			t.deleteKey(keyLeaf, key, keyRecord, -1)
 			return nil
 		}
 	}
 
-	return errors.New("the delete key not found")
+	return keyError(ErrKeyNotFound, key)
 }
 
 // deleteKey :删除key 需要多次调用 所以封装单独func
-func (t *Tree) deleteKey(n *Node, key int, pointer interface{}, keyIndex int) {
+func (t *Tree[K, V]) deleteKey(n *Node[K, V], key K, pointer interface{}, keyIndex int) {
 	n = t.removeKeyFromNode(n, key, pointer, keyIndex)
 
-	// 删除的是root节点的key
-	if n == t.Root {
-		t.adjustRoot()
+	// 删除之后node中的key数量合理 处理结束
+	if n.Count >= t.minLimit {
 		return
 	}
 
-	// 删除之后node中的key数量合理 处理结束
-	if n.Count >= MIN_LIMIT {
+	// 删除的是root节点的key(用Parent判断 并发模式下只有持有父节点latch时才会走到这里)
+	if n.Parent == nil {
+		t.adjustRoot()
 		return
 	}
 
 	// 其他情况
 	neighbour, neighbourIndex := n.getNeighbour()
+	neighbour = t.writable(neighbour)
 	// // neighbourIndex==-1时 neighbourKeyIndex=0（第一个合并如第二个）
 	// neighbourKeyIndex := neighbourIndex
 	// if neighbourIndex == -1 {
@@ -317,9 +381,9 @@ func (t *Tree) deleteKey(n *Node, key int, pointer interface{}, keyIndex int) {
 	// neighbourKey := n.Parent.Keys[neighbourKeyIndex]
 
 	// 如果加和数量合理就合并 不合理就重新分配（相当于合并再分裂）
-	if neighbour.Count+n.Count < MAX_LIMIT {
+	if neighbour.Count+n.Count < t.maxLimit {
 		// 合并
-		n.mergeToNode(neighbour, neighbourIndex, key, t)
+		n.mergeToNode(neighbour, neighbourIndex, t)
 	} else {
 		// 借调key 保持平衡
 		n.borrowFromNode(neighbour, neighbourIndex)
@@ -331,92 +395,121 @@ func (t *Tree) deleteKey(n *Node, key int, pointer interface{}, keyIndex int) {
 // borrowFromNode ：删除key之后 从邻节点借key
 /*
 neighbour 邻节点（被借调节点）
-neighbourKeyIndex 邻节点在父节点的keyindex
-neighbourKey 邻节点在父节点的key
+neighbourIndex 邻节点在父节点的pointer index(n是第一个子节点时为-1 邻节点在右边)
+叶子节点直接移动一个key和Record,父节点中的分隔key更新为右节点的第一个key;
+非叶子节点通过父节点旋转:父节点的分隔key下移到n,邻节点边上的key上移为新的分隔key,
+邻节点边上的子节点移到n 并更新它的Parent。
 */
-func (n *Node) borrowFromNode(neighbour *Node, neighbourIndex int) {
-	if !n.IsLeaf {
-		fmt.Println("redistributeNodes的不是leaf节点！！！！")
-	}
-
-	// neighbourIndex==-1时 neighbourKeyIndex=0（第一个从第二个借）
-	neighbourKeyIndex := neighbourIndex - 1
+func (n *Node[K, V]) borrowFromNode(neighbour *Node[K, V], neighbourIndex int) {
+	// 两个节点之间的分隔key在父节点的keyindex
+	separatorIndex := neighbourIndex
 	if neighbourIndex == -1 {
-		// 第二节点keyindex==0
-		neighbourKeyIndex = 0
+		separatorIndex = 0
 	}
+	parent := n.Parent
 
 	if neighbourIndex == -1 {
 		// neighbourIndex==-1 左从右借（第一个从第二个借） 右边第一个append给左边最后一个
-		n.Keys = append(n.Keys, neighbour.Keys[0])
-		n.Pointers = append(n.Pointers, neighbour.Pointers[0])
+		moved := neighbour.Pointers[0]
+		if n.IsLeaf {
+			n.Keys = append(n.Keys, neighbour.Keys[0])
+			parent.Keys[separatorIndex] = neighbour.Keys[1]
+		} else {
+			n.Keys = append(n.Keys, parent.Keys[separatorIndex])
+			parent.Keys[separatorIndex] = neighbour.Keys[0]
+			moved.(*Node[K, V]).Parent = n
+		}
+		n.Pointers = append(n.Pointers, moved)
 		// 删除邻节点被借调的key和pointer
 		neighbour.Keys = neighbour.Keys[1:]
 		neighbour.Pointers = neighbour.Pointers[1:]
-		//父节点更新
-		n.Parent.Keys[neighbourKeyIndex] = neighbour.Keys[0]
 	} else {
 		// neighbourIndex != -1 右从左借 左边最后一个 push给右边（append参数倒置）
-		n.Keys = append([]int{neighbour.Keys[len(neighbour.Keys)-1]}, n.Keys...)
-		n.Pointers = append([]interface{}{neighbour.Pointers[len(neighbour.Pointers)-1]}, n.Pointers...)
+		last := len(neighbour.Keys) - 1
+		moved := neighbour.Pointers[len(neighbour.Pointers)-1]
+		if n.IsLeaf {
+			n.Keys = append([]K{neighbour.Keys[last]}, n.Keys...)
+			parent.Keys[separatorIndex] = neighbour.Keys[last]
+		} else {
+			n.Keys = append([]K{parent.Keys[separatorIndex]}, n.Keys...)
+			parent.Keys[separatorIndex] = neighbour.Keys[last]
+			moved.(*Node[K, V]).Parent = n
+		}
+		n.Pointers = append([]interface{}{moved}, n.Pointers...)
 		// 删除邻节点被借调的key和pointer
-		neighbour.Keys = neighbour.Keys[:len(neighbour.Keys)-1]
+		neighbour.Keys = neighbour.Keys[:last]
 		neighbour.Pointers = neighbour.Pointers[:len(neighbour.Pointers)-1]
-		//父节点更新
-		nKeyIndex := neighbourKeyIndex + 1 // 右从左借 更新右在parent的key 所以+1
-		n.Parent.Keys[nKeyIndex] = n.Keys[0]
 	}
 
 	n.Count++
 	neighbour.Count--
-	return
+	n.recount()
+	neighbour.recount()
 }
 
 // mergeToNode ：删除key之后 合并入邻节点
 /*
-neighbour 邻节点（被借调节点）
-neighbourKeyIndex 邻节点在父节点的keyindex
-neighbourKey 邻节点在父节点的key
+neighbour 邻节点（合并后保留的节点）
+neighbourIndex 邻节点在父节点的pointer index(n是第一个子节点时为-1 邻节点在右边)
+非叶子节点合并时父节点中的分隔key下移到两个节点的key之间,n的子节点改为指向neighbour;
+最后递归删除父节点中的分隔key和指向n的pointer。
 */
-func (n *Node) mergeToNode(neighbour *Node, neighbourIndex int, deletekey int, t *Tree) {
+func (n *Node[K, V]) mergeToNode(neighbour *Node[K, V], neighbourIndex int, t *Tree[K, V]) {
+	// 两个节点之间的分隔key在父节点的keyindex
+	separatorIndex := neighbourIndex
+	if neighbourIndex == -1 {
+		separatorIndex = 0
+	}
+	separator := n.Parent.Keys[separatorIndex]
+
+	// 非叶子节点合并 分隔key下移
+	middle := []K{}
+	if !n.IsLeaf {
+		middle = append(middle, separator)
+		for _, p := range n.Pointers {
+			p.(*Node[K, V]).Parent = neighbour
+		}
+	}
+
 	// neighbourIndex==-1 左往右合并 反之右往左合并
 	if neighbourIndex == -1 {
 		// 左往右合并
-		neighbour.Keys = append(append([]int{}, n.Keys...), neighbour.Keys...)
+		neighbour.Keys = append(append(append([]K{}, n.Keys...), middle...), neighbour.Keys...)
 		neighbour.Pointers = append(append([]interface{}{}, n.Pointers...), neighbour.Pointers...)
 		// 双向链表维护
 		if n.IsLeaf && neighbour.IsLeaf {
 			neighbour.Prev = n.Prev
+			if n.Prev != nil {
+				n.Prev.Next = neighbour
+			}
 		}
-
-		// 左往右合并 neighbourKey只能是parent的第一个key
-		neighbourKey := n.Parent.Keys[0]
-
-		// 递归删除父节点的key和pointer（key是neighbour的，pointer是n的）
-		t.deleteKey(n.Parent, neighbourKey, n, -1)
 	} else {
 		// 右往左合并
-		neighbour.Keys = append(neighbour.Keys, n.Keys...)
+		neighbour.Keys = append(append(neighbour.Keys, middle...), n.Keys...)
 		neighbour.Pointers = append(neighbour.Pointers, n.Pointers...)
 		// 双向链表维护
 		if n.IsLeaf && neighbour.IsLeaf {
 			neighbour.Next = n.Next
+			if n.Next != nil {
+				n.Next.Prev = neighbour
+			}
 		}
-
-		// 递归删除父节点的key和pointer（key是n的，pointer是n的）
-		t.deleteKey(n.Parent, deletekey, n, neighbourIndex)
 	}
+	neighbour.Count = len(neighbour.Keys)
+	neighbour.recount()
 
-	neighbour.Count += n.Count
+	// 递归删除父节点的分隔key和指向n的pointer
+	t.deleteKey(n.Parent, separator, n, separatorIndex)
 }
 
 // removeKeyFromNode :执行remove key 返回被删除的node
-func (t *Tree) removeKeyFromNode(n *Node, key int, pointer interface{}, keyIndex int) *Node {
+func (t *Tree[K, V]) removeKeyFromNode(n *Node[K, V], key K, pointer interface{}, keyIndex int) *Node[K, V] {
+	n = t.writable(n)
 	i := -1
 	if keyIndex > -1 {
 		i = keyIndex
 	} else {
-		i = n.getKeyIndex(key)
+		i = n.getKeyIndex(key, t.compare)
 	}
 
 	j := n.getPointerIndex(pointer)
@@ -433,8 +526,8 @@ func (t *Tree) removeKeyFromNode(n *Node, key int, pointer interface{}, keyIndex
 }
 
 // root中的key被删之后,重新调整root
-func (t *Tree) adjustRoot() {
-	var newRoot *Node
+func (t *Tree[K, V]) adjustRoot() {
+	var newRoot *Node[K, V]
 
 	// root还剩key 不做任何处理
 	if t.Root.Count > 0 {
@@ -446,7 +539,7 @@ func (t *Tree) adjustRoot() {
 		newRoot = nil
 	} else {
 		// root被删空 说明之前只有一个key 子节点设置成新root即可
-		newRoot, _ = t.Root.Pointers[0].(*Node)
+		newRoot, _ = t.Root.Pointers[0].(*Node[K, V])
 		newRoot.Parent = nil
 	}
 	t.Root = newRoot
@@ -455,15 +548,15 @@ func (t *Tree) adjustRoot() {
 }
 
 // 根据节点获取相邻节点 (首节点的相邻是右节点，此时index返回-1,其他的相邻是左节点，返回对应index)
-func (n *Node) getNeighbour() (neigh *Node, i int) {
+func (n *Node[K, V]) getNeighbour() (neigh *Node[K, V], i int) {
 	for i = 0; i <= n.Parent.Count; i++ {
-		if reflect.DeepEqual(n.Parent.Pointers[i], n) {
+		if n.Parent.Pointers[i] == n {
 			if i == 0 {
 				i = -1
-				neigh, _ = n.Parent.Pointers[1].(*Node)
+				neigh, _ = n.Parent.Pointers[1].(*Node[K, V])
 			} else {
 				i = i - 1
-				neigh, _ = n.Parent.Pointers[i].(*Node)
+				neigh, _ = n.Parent.Pointers[i].(*Node[K, V])
 			}
 			break
 		}
@@ -473,25 +566,37 @@ func (n *Node) getNeighbour() (neigh *Node, i int) {
 
 // *********************** Find和Print等 ***********************
 
-// Find :查找key
-func (t *Tree) Find(key int) (*Record, error) {
-	leaf := t.findLeaf(key)
-	if leaf == nil {
-		return nil, errors.New("key not found")
+// Find :查找key key不存在时返回ErrKeyNotFound
+func (t *Tree[K, V]) Find(key K) (*Record[V], error) {
+	if r := t.lookup(key); r != nil {
+		return r, nil
 	}
+	return nil, keyError(ErrKeyNotFound, key)
+}
 
-	i := leaf.getKeyIndex(key)
-	if i == -1 {
-		return nil, errors.New("key not found")
+// Get :查找key的value 不存在时ok为false
+func (t *Tree[K, V]) Get(key K) (value V, ok bool) {
+	if r := t.lookup(key); r != nil {
+		return r.Value, true
 	}
+	return value, false
+}
 
-	r, _ := leaf.Pointers[i].(*Record)
+// 查找key的Record 不存在时返回nil
+func (t *Tree[K, V]) lookup(key K) *Record[V] {
+	if t.concurrent {
+		return t.concurrentFind(key)
+	}
 
-	return r, nil
+	leaf := t.findLeaf(key)
+	if leaf == nil {
+		return nil
+	}
+	return leaf.getRecord(key, t.compare)
 }
 
 // 查找key对应的leaf,isParentContain：父节点是否包含此key
-func (t *Tree) findLeaf(key int) *Node {
+func (t *Tree[K, V]) findLeaf(key K) *Node[K, V] {
 	n := t.Root
 	// 空树
 	if n == nil {
@@ -499,25 +604,25 @@ func (t *Tree) findLeaf(key int) *Node {
 		return nil
 	}
 
-	for i := 0; !n.IsLeaf; i = 0 {
-		for i < n.Count {
-			if n.Keys[i] <= key {
-				i++
-			} else {
-				break
-			}
-		}
-
+	for !n.IsLeaf {
 		// 切换至下层节点
-		n, _ = n.Pointers[i].(*Node)
+		n, _ = n.Pointers[n.getChildIndex(key, t.compare)].(*Node[K, V])
 	}
 
 	return n
 }
 
+// 非叶子节点中 key所在子树的pointer index
+func (n *Node[K, V]) getChildIndex(key K, compare func(a, b K) int) (i int) {
+	for i < n.Count && compare(n.Keys[i], key) <= 0 {
+		i++
+	}
+	return
+}
+
 // PrintTree :打印输出Tree(层次遍历 借助队列实现)
-func (t *Tree) PrintTree() {
-	var n *Node
+func (t *Tree[K, V]) PrintTree() {
+	var n *Node[K, V]
 	count := 0
 
 	if t.Root == nil {
@@ -525,11 +630,11 @@ func (t *Tree) PrintTree() {
 		return
 	}
 
-	queue = list.New()
+	queue := list.New() // 遍历Tree队列(链表)
 	queue.PushBack(t.Root)
 	for queue.Len() > 0 {
 		// 队列中取值
-		n = queue.Front().Value.(*Node)
+		n = queue.Front().Value.(*Node[K, V])
 		queue.Remove(queue.Front()) // remove front element
 		if n == nil {
 			continue
@@ -549,7 +654,7 @@ func (t *Tree) PrintTree() {
 				fmt.Printf("[")
 			}
 
-			fmt.Printf("%d", n.Keys[i])
+			fmt.Printf("%v", n.Keys[i])
 
 			if i < len(n.Keys)-1 {
 				fmt.Printf(", ")
@@ -573,18 +678,18 @@ func (t *Tree) PrintTree() {
 				if i > len(n.Pointers)-1 {
 					fmt.Println(n)
 				}
-				c, _ := n.Pointers[i].(*Node)
+				c, _ := n.Pointers[i].(*Node[K, V])
 				queue.PushBack(c)
 			}
 		}
 	}
 
 	height := t.height()
-	fmt.Printf("\n阶数%d - 高度%d - key数%d\n", ORDER, height, count)
+	fmt.Printf("\n阶数%d - 高度%d - key数%d\n", t.order, height, count)
 }
 
 // PrintLeaves :打印输出所有叶子节点
-func (t *Tree) PrintLeaves() {
+func (t *Tree[K, V]) PrintLeaves() {
 	if t.Root == nil {
 		fmt.Printf("Empty tree.\n")
 		return
@@ -593,13 +698,13 @@ func (t *Tree) PrintLeaves() {
 	n := t.Root
 	// 找到最小leaf
 	for !n.IsLeaf {
-		n, _ = n.Pointers[0].(*Node)
+		n, _ = n.Pointers[0].(*Node[K, V])
 	}
 
 	for n != nil {
 		fmt.Printf("[")
 		for i, key := range n.Keys {
-			fmt.Printf("%d", key)
+			fmt.Printf("%v", key)
 			if i < len(n.Keys)-1 {
 				fmt.Printf(", ")
 			}
@@ -614,25 +719,25 @@ func (t *Tree) PrintLeaves() {
 }
 
 // FindAndPrint :查找并打印
-func (t *Tree) FindAndPrint(key int) {
+func (t *Tree[K, V]) FindAndPrint(key K) {
 	r, err := t.Find(key)
 
 	if err != nil || r == nil {
-		fmt.Printf("Record not found under key %d.\n", key)
+		fmt.Printf("Record not found under key %v.\n", key)
 	} else {
-		fmt.Printf("Record at %d -- key %d, value %s.\n", r, key, r.Value)
+		fmt.Printf("Record at %p -- key %v, value %v.\n", r, key, r.Value)
 	}
 }
 
 // FindAndPrintRange :查找并打印范围数据
-func (t *Tree) FindAndPrintRange(keyMin, keyMax int) (count int) {
+func (t *Tree[K, V]) FindAndPrintRange(keyMin, keyMax K) (count int) {
 	keys, records := t.FindRange(keyMin, keyMax)
 	if len(keys) == 0 {
 		fmt.Println("None found")
 	} else {
 		fmt.Printf("Find %d keys\n", len(keys))
 		for i, key := range keys {
-			fmt.Printf("Key: %d  Location: %d  Value: %s\n",
+			fmt.Printf("Key: %v  Location: %p  Value: %v\n",
 				key,
 				records[i],
 				records[i].Value,
@@ -643,34 +748,13 @@ func (t *Tree) FindAndPrintRange(keyMin, keyMax int) (count int) {
 	return len(keys)
 }
 
-// FindRange :范围查找
-func (t *Tree) FindRange(keyMin int, keyMax int) ([]int, []*Record) {
-	n := t.findLeaf(keyMin)
-	if n == nil {
-		return nil, nil
-	}
-
-	keys := make([]int, 0, 0)
-	records := make([]*Record, 0, 0)
-
-	for n != nil && n.Keys[len(n.Keys)-1] < keyMax {
-		for i, key := range n.Keys {
-			if key > keyMax || key < keyMin {
-				continue
-			}
-
-			keys = append(keys, key)
-			records = append(records, n.Pointers[i].(*Record))
-		}
-
-		n = n.Next
-	}
-
-	return keys, records
+// FindRange :范围查找 返回keyMin<=key<=keyMax的数据
+func (t *Tree[K, V]) FindRange(keyMin K, keyMax K) ([]K, []*Record[V]) {
+	return t.FindRangeQuery(RangeQuery[K]{Lower: Included(keyMin), Upper: Included(keyMax)})
 }
 
 // 获取tree高度
-func (t *Tree) height() int {
+func (t *Tree[K, V]) height() int {
 	h := 1
 	n := t.Root
 	if n == nil {
@@ -678,13 +762,13 @@ func (t *Tree) height() int {
 	}
 
 	for ; !n.IsLeaf; h++ {
-		n, _ = n.Pointers[0].(*Node)
+		n, _ = n.Pointers[0].(*Node[K, V])
 	}
 	return h
 }
 
 // 回到根节点的步数
-func (t *Tree) getStepsToRoot(child *Node) int {
+func (t *Tree[K, V]) getStepsToRoot(child *Node[K, V]) int {
 	length := 0
 	c := child
 	for c != t.Root {
@@ -696,15 +780,15 @@ func (t *Tree) getStepsToRoot(child *Node) int {
 
 // ************************* constructor *************************
 
-func newRecord(value []byte) (*Record, error) {
-	r := &Record{}
+func newRecord[V any](value V) (*Record[V], error) {
+	r := &Record[V]{}
 	r.Value = value
 	return r, nil
 }
 
-func newNode() (*Node, error) {
-	n := &Node{}
-	n.Keys = make([]int, 0)             //! 每个节点的最大数据量比阶数少1(MAX_LIMIT) 这里cap是考虑临时插入的情况+1
+func newNode[K any, V any]() (*Node[K, V], error) {
+	n := &Node[K, V]{}
+	n.Keys = make([]K, 0)               //! 每个节点的最大数据量比阶数少1(MAX_LIMIT) 这里cap是考虑临时插入的情况+1
 	n.Pointers = make([]interface{}, 0) //! 每个节点的最大指针数量==阶数 这里cap是考虑临时插入的情况+1
 	n.IsLeaf = false
 	n.Count = 0
@@ -713,8 +797,8 @@ func newNode() (*Node, error) {
 	return n, nil
 }
 
-func newLeaf() (*Node, error) {
-	leaf, err := newNode()
+func newLeaf[K any, V any]() (*Node[K, V], error) {
+	leaf, err := newNode[K, V]()
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,7 @@
 package bptree
 
 import (
+	"flag"
 	"fmt"
 	"math/rand"
 	"reflect"
@@ -11,7 +12,7 @@ import (
 // ************** insert test **************
 
 func TestInsertNilRoot(t *testing.T) {
-	tree := NewTree()
+	tree := NewTree[int, []byte]()
 
 	key := 1
 	value := []byte("test")
@@ -37,7 +38,7 @@ func TestInsertNilRoot(t *testing.T) {
 }
 
 func TestInsert(t *testing.T) {
-	tree := NewTree()
+	tree := NewTree[int, []byte]()
 
 	key := 1
 	value := []byte("test")
@@ -62,7 +63,7 @@ func TestInsert(t *testing.T) {
 }
 
 func TestInsertSameKeyTwice(t *testing.T) {
-	tree := NewTree()
+	tree := NewTree[int, []byte]()
 
 	key := 1
 	value := []byte("test")
@@ -96,7 +97,7 @@ func TestInsertSameKeyTwice(t *testing.T) {
 }
 
 func TestInsertSameValueTwice(t *testing.T) {
-	tree := NewTree()
+	tree := NewTree[int, []byte]()
 
 	key := 1
 	value := []byte("test")
@@ -129,7 +130,7 @@ func TestInsertSameValueTwice(t *testing.T) {
 }
 
 func TestFindNilRoot(t *testing.T) {
-	tree := NewTree()
+	tree := NewTree[int, []byte]()
 
 	r, err := tree.Find(1)
 	if err == nil {
@@ -142,7 +143,7 @@ func TestFindNilRoot(t *testing.T) {
 }
 
 func TestFind(t *testing.T) {
-	tree := NewTree()
+	tree := NewTree[int, []byte]()
 
 	key := 1
 	value := []byte("test")
@@ -167,7 +168,7 @@ func TestFind(t *testing.T) {
 }
 
 func TestFindRange(t *testing.T) {
-	tree := NewTree()
+	tree := NewTree[int, []byte]()
 	r := initRand()
 	keys := []int{3, 13, 23, 33, 43, 53, 63, 73, 83, 93, 103, 113, 123}
 
@@ -182,9 +183,66 @@ func TestFindRange(t *testing.T) {
 	}
 }
 
+func TestStringKeys(t *testing.T) {
+	tree := NewTree[string, int]()
+	keys := []string{"pear", "apple", "fig", "banana", "kiwi", "cherry", "grape", "lemon"}
+
+	for i, key := range keys {
+		if err := tree.Insert(key, i); err != nil {
+			t.Errorf("%s", err)
+		}
+	}
+
+	for i, key := range keys {
+		r, err := tree.Find(key)
+		if err != nil {
+			t.Errorf("%s\n", err)
+			continue
+		}
+		if r.Value != i {
+			t.Errorf("expected %d and got %d \n", i, r.Value)
+		}
+	}
+
+	got, _ := tree.FindRange("b", "h")
+	want := []string{"banana", "cherry", "fig", "grape"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v and got %v \n", want, got)
+	}
+}
+
+func TestCustomComparator(t *testing.T) {
+	// 倒序比较
+	tree := NewTreeFunc[uint64, string](func(a, b uint64) int {
+		if a > b {
+			return -1
+		} else if a < b {
+			return 1
+		}
+		return 0
+	})
+
+	for i := uint64(1); i <= 20; i++ {
+		_ = tree.Insert(i, fmt.Sprint(i))
+	}
+
+	n := tree.Root
+	for !n.IsLeaf {
+		n, _ = n.Pointers[0].(*Node[uint64, string])
+	}
+	if n.Keys[0] != 20 {
+		t.Errorf("expected first key 20 and got %d", n.Keys[0])
+	}
+
+	r, err := tree.Find(7)
+	if err != nil || r.Value != "7" {
+		t.Errorf("expected 7 and got %v, %v", r, err)
+	}
+}
+
 // 插入一组key 并打印tree
 func TestBatchInsertAndPrintTree(t *testing.T) {
-	tree := NewTree()
+	tree := NewTree[int, []byte]()
 	_range := 1000
 	num := 10
 	r := initRand()
@@ -200,7 +258,7 @@ func TestBatchInsertAndPrintTree(t *testing.T) {
 
 // 插入一组key 打印所有叶子节点
 func TestBatchInsertAndPrintLeaves(t *testing.T) {
-	tree := NewTree()
+	tree := NewTree[int, []byte]()
 	_range := 1000
 	num := 10
 	r := initRand()
@@ -219,7 +277,7 @@ func TestBatchInsertAndPrintLeaves(t *testing.T) {
 
 // 单点删除
 func TestSingleDelete(t *testing.T) {
-	tree := NewTree()
+	tree := NewTree[int, []byte]()
 	_range := 1000
 	num := 10
 	r := initRand()
@@ -239,7 +297,7 @@ func TestSingleDelete(t *testing.T) {
 
 	// 3. delete 该key
 	fmt.Printf("Now delete key: %d\n", curKey)
-	err = tree.Delete(curKey)
+	err := tree.Delete(curKey)
 	if err != nil {
 		t.Errorf("TestSingleDelete failed")
 	}
@@ -249,7 +307,7 @@ func TestSingleDelete(t *testing.T) {
 
 // 根节点删除
 func TestDeleteRoot(t *testing.T) {
-	tree := NewTree()
+	tree := NewTree[int, []byte]()
 	_range := 1000
 	num := 10
 	r := initRand()
@@ -264,7 +322,7 @@ func TestDeleteRoot(t *testing.T) {
 
 	for _, key := range tree.Root.Keys {
 		fmt.Printf("-------------------------\nNow delete root key [%d]\n", key)
-		err = tree.Delete(key)
+		err := tree.Delete(key)
 		tree.PrintTree()
 		if err != nil {
 			t.Errorf("TestDeleteRoot failed:%s", err)
@@ -275,18 +333,19 @@ func TestDeleteRoot(t *testing.T) {
 
 // ★★★多节点遍历删除
 func TestMultiDelete(t *testing.T) {
-	tree := NewTree()
+	tree := NewTree[int, []byte]()
 	_range := 1000
 	num := 10
 	r := initRand()
 	keys := make([]int, 0)
 
-	//  1.insert 多个随机数
-	for i := 0; i < num; i++ {
+	//  1.insert 多个随机数(跳过重复的key 否则会删除两次)
+	for len(keys) < num {
 		key := r.Intn(_range)
-		keys = append(keys, key)
 		value := getRandString(r, 4)
-		_ = tree.Insert(key, []byte(value))
+		if tree.Insert(key, []byte(value)) == nil {
+			keys = append(keys, key)
+		}
 	}
 
 	tree.PrintTree()
@@ -294,7 +353,7 @@ func TestMultiDelete(t *testing.T) {
 	// 3. 遍历delete
 	for _, key := range keys {
 		fmt.Printf("-------------------------\nNow delete key [%d]\n", key)
-		err = tree.Delete(key)
+		err := tree.Delete(key)
 		if err != nil {
 			t.Errorf("TestMultiDelete failed:%s", err)
 		}
@@ -305,7 +364,7 @@ func TestMultiDelete(t *testing.T) {
 }
 
 func TestDeleteNilTree(t *testing.T) {
-	tree := NewTree()
+	tree := NewTree[int, []byte]()
 
 	key := 1
 
@@ -325,7 +384,7 @@ func TestDeleteNilTree(t *testing.T) {
 }
 
 func TestDeleteNotFound(t *testing.T) {
-	tree := NewTree()
+	tree := NewTree[int, []byte]()
 
 	key := 1
 	value := []byte("test")
@@ -371,6 +430,19 @@ func initRand() *rand.Rand {
 	return rand.New(rand.NewSource(time.Now().Unix()))
 }
 
+var seed = flag.Int64("seed", 0, "random seed for testRand, 0 uses the current time")
+
+// testRand :带种子的随机数 种子写入测试日志 失败时用-seed复现
+func testRand(t testing.TB) *rand.Rand {
+	t.Helper()
+	s := *seed
+	if s == 0 {
+		s = time.Now().UnixNano()
+	}
+	t.Logf("random seed %d (rerun with -seed=%d)", s, s)
+	return rand.New(rand.NewSource(s))
+}
+
 // RandString 生成随机字符串
 func getRandString(r *rand.Rand, len int) string {
 	bytes := make([]byte, len)
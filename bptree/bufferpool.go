@@ -0,0 +1,384 @@
+package bptree
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// *********************** Buffer Pool ***********************
+/*
+BufferPool在内存中缓存固定数量的page(frame),page的读写都先经过它:
+1. FetchPage/NewPage返回的page处于pin状态,用完之后必须UnpinPage,被pin住的page不会被淘汰。
+2. 修改过的page标记为dirty,被淘汰或者Flush时才写回文件。
+3. 没有空闲frame时使用CLOCK算法淘汰:指针循环扫描frame,引用位为1的清零给第二次机会,遇到引用位为0且没有被pin的frame就淘汰。
+从文件读入page时校验crc32,写回时由Pager重新计算。
+空闲page链表也由BufferPool维护(空闲标记写在缓存的page中),这样所有对文件的修改都经过缓存。
+
+关联WAL之后还遵循两条规则:
+1. 一次操作修改过的page在Commit把它们写入WAL之前不会被淘汰(no-steal),所有frame都被占用时临时超出数量上限,
+   Commit之后淘汰多出的frame,回到数量上限。
+2. dirty page写回文件之前先把WAL刷盘(write-ahead)。
+3. 写操作以Begin开始,以Commit或Abort结束。中途出错时Abort丢弃修改了一半的page和元数据,不写入WAL。
+   已经提交但还没有写回文件的page在写操作中第一次被访问时保存一份内容,Abort时恢复,
+   其余修改过的page直接丢弃,下次从文件读取。只读的访问不在写操作中,不保存内容。
+*/
+
+// DefaultPoolSize :默认缓存的page数量
+const DefaultPoolSize = 256
+
+// PoolStats :buffer pool的统计信息
+type PoolStats struct {
+	Hits      uint64 // 命中缓存的次数
+	Misses    uint64 // 需要从文件读取的次数
+	Evictions uint64 // 淘汰的page数量
+	Flushes   uint64 // 写回文件的page数量
+}
+
+type frame struct {
+	id    PageID
+	data  []byte
+	pins  int    // pin计数
+	dirty bool   // 是否需要写回
+	ref   bool   // CLOCK引用位
+	held  bool   // 修改还没有写入WAL
+	undo  []byte // 本次操作开始时的内容 只在page已经提交但还没有写回时保存
+}
+
+// BufferPool :page缓存
+type BufferPool struct {
+	mu     sync.Mutex
+	pager  *Pager
+	frames []*frame          // 已经分配的frame 操作之间最多size个
+	size   int               // frame数量上限
+	table  map[PageID]*frame // page -> frame
+	hand   int               // CLOCK指针
+	stats  PoolStats
+	log    *wal     // 为nil时不记录WAL
+	held   []*frame // 本次操作修改过 等待Commit的page
+	saved  []*frame // 本次操作中保存了undo的page
+	active bool     // 在Begin和Commit/Abort之间 只有这时保存undo
+	meta   meta     // 上一次Commit时的元数据 Abort时恢复
+}
+
+// NewBufferPool :创建最多缓存size个page的buffer pool
+func NewBufferPool(pager *Pager, size int) *BufferPool {
+	if size < 1 {
+		size = 1
+	}
+	return &BufferPool{
+		pager: pager,
+		size:  size,
+		table: make(map[PageID]*frame, size),
+		meta:  pager.meta,
+	}
+}
+
+// FetchPage :获取page并pin住 返回的切片在UnpinPage之前有效
+func (bp *BufferPool) FetchPage(id PageID) ([]byte, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	f, err := bp.fetch(id)
+	if err != nil {
+		return nil, err
+	}
+	return f.data, nil
+}
+
+// NewPage :分配一个page并pin住 优先复用空闲链表 内容清零且标记为dirty
+func (bp *BufferPool) NewPage() (PageID, []byte, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	id := bp.pager.meta.freeHead
+	var f *frame
+	if id != nilPage {
+		var err error
+		if f, err = bp.fetch(id); err != nil {
+			return nilPage, nil, err
+		}
+		if f.data[4] != pageFree {
+			f.pins--
+			return nilPage, nil, fmt.Errorf("%w: free page %d", ErrCorrupt, id)
+		}
+		bp.pager.meta.freeHead = PageID(binary.BigEndian.Uint64(f.data[7:15]))
+	} else {
+		var err error
+		if f, err = bp.victim(); err != nil {
+			return nilPage, nil, err
+		}
+		id = bp.pager.grow()
+		bp.install(f, id)
+	}
+
+	clear(f.data)
+	bp.markDirty(f)
+	return id, f.data, nil
+}
+
+// UnpinPage :释放pin dirty表示调用方修改了page内容
+func (bp *BufferPool) UnpinPage(id PageID, dirty bool) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	if f, ok := bp.table[id]; ok && f.pins > 0 {
+		f.pins--
+		if dirty {
+			bp.markDirty(f)
+		}
+	}
+}
+
+// FreePage :释放page 加入空闲链表
+func (bp *BufferPool) FreePage(id PageID) error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	f, ok := bp.table[id]
+	if ok && f.pins > 0 {
+		return fmt.Errorf("%w: free page %d", ErrPagePinned, id)
+	}
+	if ok {
+		bp.save(f)
+	} else {
+		// page的旧内容不再需要 不用从文件读取
+		if id == nilPage || uint64(id) >= bp.pager.meta.pageCount {
+			return fmt.Errorf("%w: page %d out of range", ErrCorrupt, id)
+		}
+		var err error
+		if f, err = bp.victim(); err != nil {
+			return err
+		}
+		bp.install(f, id)
+		f.pins = 0
+	}
+
+	clear(f.data)
+	f.data[4] = pageFree
+	binary.BigEndian.PutUint64(f.data[7:15], uint64(bp.pager.meta.freeHead))
+	bp.markDirty(f)
+	bp.pager.meta.freeHead = id
+	return nil
+}
+
+// Begin :开始一次写操作 之后第一次访问的已提交page保存undo 直到Commit或Abort
+func (bp *BufferPool) Begin() {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	bp.active = bp.log != nil
+}
+
+// Commit :把本次操作修改过的page和元数据作为一条记录写入WAL 之后这些page才可以被淘汰
+/*
+写入失败时page保持在等待状态,下一次Commit会重新写入。
+*/
+func (bp *BufferPool) Commit() error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	if bp.log == nil {
+		return nil
+	}
+	if len(bp.held) > 0 {
+		if err := bp.log.append(bp.pager.meta, bp.held); err != nil {
+			return err
+		}
+	}
+	bp.release()
+	return bp.shrink()
+}
+
+// Abort :丢弃本次操作对page和元数据的修改 恢复到上一次Commit之后的状态
+/*
+操作中途出错时代替Commit调用,修改了一半的page不会写入WAL,也不会写回文件。
+*/
+func (bp *BufferPool) Abort() error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	if bp.log == nil {
+		return nil
+	}
+	for _, f := range bp.held {
+		if f.undo != nil {
+			// 已经提交的内容只在缓存和WAL中 恢复为操作开始时的内容
+			copy(f.data, f.undo)
+			continue
+		}
+		delete(bp.table, f.id)
+		f.id, f.pins, f.dirty, f.ref = nilPage, 0, false, false
+	}
+	bp.pager.meta = bp.meta
+	bp.release()
+	return bp.shrink()
+}
+
+// 操作结束 清除等待状态和undo 记录当前的元数据
+func (bp *BufferPool) release() {
+	for _, f := range bp.held {
+		f.held = false
+	}
+	for _, f := range bp.saved {
+		f.undo = nil
+	}
+	bp.held = bp.held[:0]
+	bp.saved = bp.saved[:0]
+	bp.meta = bp.pager.meta
+	bp.active = false
+}
+
+// 已经提交但还没有写回的page在本次写操作中第一次被访问时 保存当前内容
+func (bp *BufferPool) save(f *frame) {
+	if !bp.active || !f.dirty || f.held || f.undo != nil {
+		return
+	}
+	f.undo = slices.Clone(f.data)
+	bp.saved = append(bp.saved, f)
+}
+
+// FlushAll :把所有dirty page写回文件(不刷盘)
+func (bp *BufferPool) FlushAll() error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	for _, f := range bp.frames {
+		if err := bp.flush(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats :统计信息
+func (bp *BufferPool) Stats() PoolStats {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return bp.stats
+}
+
+func (bp *BufferPool) flush(f *frame) error {
+	if f.id == nilPage || !f.dirty {
+		return nil
+	}
+	if bp.log != nil {
+		if err := bp.log.sync(); err != nil {
+			return err
+		}
+	}
+	if err := bp.pager.WritePage(f.id, f.data); err != nil {
+		return err
+	}
+	f.dirty = false
+	bp.stats.Flushes++
+	return nil
+}
+
+// 找一个可用的frame:先用未分配的 再用空闲的 最后按CLOCK淘汰
+func (bp *BufferPool) victim() (*frame, error) {
+	if len(bp.frames) < bp.size {
+		f := &frame{data: make([]byte, bp.pager.pageSize)}
+		bp.frames = append(bp.frames, f)
+		return f, nil
+	}
+
+	// 最多扫描两圈:第一圈清除引用位 第二圈一定能找到没有被pin的frame
+	for i := 0; i < 2*len(bp.frames); i++ {
+		f := bp.frames[bp.hand]
+		bp.hand = (bp.hand + 1) % len(bp.frames)
+
+		if f.id == nilPage {
+			return f, nil
+		}
+		if f.pins > 0 || f.held {
+			continue
+		}
+		if f.ref {
+			f.ref = false
+			continue
+		}
+
+		if err := bp.flush(f); err != nil {
+			return nil, err
+		}
+		delete(bp.table, f.id)
+		f.id = nilPage
+		bp.stats.Evictions++
+		return f, nil
+	}
+
+	if len(bp.held) > 0 {
+		// 等待写入WAL的page不能写回文件 临时增加frame
+		f := &frame{data: make([]byte, bp.pager.pageSize)}
+		bp.frames = append(bp.frames, f)
+		return f, nil
+	}
+	return nil, ErrPoolFull
+}
+
+// 淘汰no-steal时临时增加的frame 被pin住的frame保留到下一次Commit
+func (bp *BufferPool) shrink() error {
+	for i := 0; i < len(bp.frames) && len(bp.frames) > bp.size; {
+		f := bp.frames[i]
+		if f.pins > 0 || f.held {
+			i++
+			continue
+		}
+		if err := bp.flush(f); err != nil {
+			return err
+		}
+		if f.id != nilPage {
+			delete(bp.table, f.id)
+			bp.stats.Evictions++
+		}
+		bp.frames = slices.Delete(bp.frames, i, i+1)
+	}
+	if bp.hand >= len(bp.frames) {
+		bp.hand = 0
+	}
+	return nil
+}
+
+func (bp *BufferPool) install(f *frame, id PageID) {
+	f.id = id
+	f.pins = 1
+	f.dirty = false
+	f.ref = true
+	f.undo = nil
+	bp.table[id] = f
+}
+
+// 从缓存或文件中获取page并pin住
+func (bp *BufferPool) fetch(id PageID) (*frame, error) {
+	if f, ok := bp.table[id]; ok {
+		bp.stats.Hits++
+		bp.save(f)
+		f.pins++
+		f.ref = true
+		return f, nil
+	}
+
+	bp.stats.Misses++
+	f, err := bp.victim()
+	if err != nil {
+		return nil, err
+	}
+	if err := bp.pager.ReadPage(id, f.data); err != nil {
+		return nil, err
+	}
+	if !checksumOK(f.data) {
+		return nil, fmt.Errorf("%w: page %d", ErrCorrupt, id)
+	}
+
+	bp.install(f, id)
+	return f, nil
+}
+
+func (bp *BufferPool) markDirty(f *frame) {
+	f.dirty = true
+	if bp.log != nil && !f.held {
+		f.held = true
+		bp.held = append(bp.held, f)
+	}
+}
@@ -0,0 +1,285 @@
+package bptree
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func openTestPool(t *testing.T, size int) *BufferPool {
+	t.Helper()
+	p, err := OpenPager(filepath.Join(t.TempDir(), "pages.db"), minPageSize)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return NewBufferPool(p, size)
+}
+
+// dirty page被淘汰时写回文件 再次读取时内容不变
+func TestBufferPoolWriteBack(t *testing.T) {
+	bp := openTestPool(t, 3)
+
+	ids := make([]PageID, 0)
+	for i := 0; i < 10; i++ {
+		id, page, err := bp.NewPage()
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		page[100] = byte(i)
+		bp.UnpinPage(id, true)
+		ids = append(ids, id)
+	}
+	if stats := bp.Stats(); stats.Evictions != 7 || stats.Flushes != 7 {
+		t.Errorf("expected 7 evictions and 7 flushes, got %+v", stats)
+	}
+
+	for i, id := range ids {
+		page, err := bp.FetchPage(id)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		if page[100] != byte(i) {
+			t.Errorf("page %d: expected %d and got %d", id, i, page[100])
+		}
+		bp.UnpinPage(id, false)
+	}
+
+	// FlushAll之后直接从Pager读取也能看到最新内容
+	if err := bp.FlushAll(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	buf := make([]byte, bp.pager.PageSize())
+	for i, id := range ids {
+		if err := bp.pager.ReadPage(id, buf); err != nil || buf[100] != byte(i) || !checksumOK(buf) {
+			t.Errorf("page %d: unexpected content on disk, %v", id, err)
+		}
+	}
+}
+
+func TestBufferPoolPinned(t *testing.T) {
+	bp := openTestPool(t, 2)
+
+	a, _, _ := bp.NewPage()
+	b, _, _ := bp.NewPage()
+	if _, _, err := bp.NewPage(); !errors.Is(err, ErrPoolFull) {
+		t.Errorf("expected ErrPoolFull and got %v", err)
+	}
+	if err := bp.FreePage(a); !errors.Is(err, ErrPagePinned) {
+		t.Errorf("expected ErrPagePinned and got %v", err)
+	}
+
+	// 释放一个pin之后 被pin住的page不会被淘汰
+	bp.UnpinPage(a, true)
+	c, _, err := bp.NewPage()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, ok := bp.table[b]; !ok {
+		t.Errorf("pinned page %d was evicted", b)
+	}
+	if _, ok := bp.table[a]; ok {
+		t.Errorf("expected page %d to be evicted", a)
+	}
+	bp.UnpinPage(b, false)
+	bp.UnpinPage(c, false)
+}
+
+// CLOCK:最近访问过的page得到第二次机会
+func TestBufferPoolClock(t *testing.T) {
+	bp := openTestPool(t, 3)
+
+	ids := make([]PageID, 0)
+	for i := 0; i < 3; i++ {
+		id, _, _ := bp.NewPage()
+		bp.UnpinPage(id, true)
+		ids = append(ids, id)
+	}
+
+	// 第一次淘汰时所有引用位都是1 扫描一圈后淘汰第一个page
+	d, _, _ := bp.NewPage()
+	bp.UnpinPage(d, true)
+	if _, ok := bp.table[ids[0]]; ok {
+		t.Errorf("expected page %d to be evicted", ids[0])
+	}
+
+	// 访问ids[1]之后 下一次淘汰跳过它选择ids[2]
+	if _, err := bp.FetchPage(ids[1]); err != nil {
+		t.Fatalf("%s", err)
+	}
+	bp.UnpinPage(ids[1], false)
+	e, _, _ := bp.NewPage()
+	bp.UnpinPage(e, true)
+	if _, ok := bp.table[ids[1]]; !ok {
+		t.Errorf("expected page %d to stay in pool", ids[1])
+	}
+	if _, ok := bp.table[ids[2]]; ok {
+		t.Errorf("expected page %d to be evicted", ids[2])
+	}
+}
+
+func TestBufferPoolStats(t *testing.T) {
+	bp := openTestPool(t, 1)
+	a, _, _ := bp.NewPage()
+	bp.UnpinPage(a, true)
+	b, _, _ := bp.NewPage()
+	bp.UnpinPage(b, true)
+
+	for i := 0; i < 5; i++ {
+		_, _ = bp.FetchPage(b)
+		bp.UnpinPage(b, false)
+	}
+	_, _ = bp.FetchPage(a)
+	bp.UnpinPage(a, false)
+	stats := bp.Stats()
+	if stats.Hits != 5 || stats.Misses != 1 || stats.Evictions != 2 {
+		t.Errorf("expected 5 hits, 1 miss and 2 evictions, got %+v", stats)
+	}
+}
+
+// 释放的page留在缓存中 按后进先出的顺序复用
+func TestBufferPoolFreeList(t *testing.T) {
+	bp := openTestPool(t, 2)
+	ids := make([]PageID, 0)
+	for i := 0; i < 4; i++ {
+		id, _, _ := bp.NewPage()
+		bp.UnpinPage(id, true)
+		ids = append(ids, id)
+	}
+	_ = bp.FreePage(ids[1])
+	_ = bp.FreePage(ids[3])
+
+	count := bp.pager.PageCount()
+	for _, want := range []PageID{ids[3], ids[1]} {
+		id, page, err := bp.NewPage()
+		if err != nil || id != want || page[4] != 0 {
+			t.Errorf("expected page %d and got %d, %v", want, id, err)
+		}
+		bp.UnpinPage(id, true)
+	}
+	if bp.pager.PageCount() != count {
+		t.Errorf("expected %d pages and got %d", count, bp.pager.PageCount())
+	}
+}
+
+// 很小的buffer pool下随机操作 结果与map一致
+func TestDiskTreeSmallPool(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.db")
+	tree := openTestDiskTree(t, path, WithOrder(6), WithPageSize(512), WithPoolSize(4))
+	model := make(map[int]string)
+	r := testRand(t)
+
+	for i := 0; i < 5000; i++ {
+		key := r.Intn(800)
+		if r.Intn(3) > 0 {
+			if tree.Insert(key, fmt.Sprint(key)) == nil {
+				model[key] = fmt.Sprint(key)
+			}
+		} else if tree.Delete(key) == nil {
+			delete(model, key)
+		}
+	}
+	checkDiskTree(t, tree, model)
+	if stats := tree.PoolStats(); stats.Misses == 0 || stats.Evictions == 0 {
+		t.Errorf("expected misses and evictions with a small pool, got %+v", stats)
+	}
+	if err := tree.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	tree = openTestDiskTree(t, path)
+	defer tree.Close()
+	checkDiskTree(t, tree, model)
+}
+
+// 阶数很小时一次操作修改的page超过pool大小 Commit之后回到数量上限
+func TestBufferPoolShrink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.db")
+	tree := openTestDiskTree(t, path, WithOrder(3), WithPageSize(512), WithPoolSize(2))
+	defer tree.Close()
+	model := make(map[int]string)
+	r := testRand(t)
+
+	for i := 0; i < 5000; i++ {
+		key := r.Intn(800)
+		if r.Intn(3) > 0 {
+			if tree.Insert(key, fmt.Sprint(key)) == nil {
+				model[key] = fmt.Sprint(key)
+			}
+		} else if tree.Delete(key) == nil {
+			delete(model, key)
+		}
+		if n := len(tree.pool.frames); n > tree.pool.size {
+			t.Fatalf("op %d: %d frames after commit, limit is %d", i, n, tree.pool.size)
+		}
+	}
+	if err := tree.Checkpoint(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if n := len(tree.pool.frames); n > tree.pool.size {
+		t.Errorf("%d frames after checkpoint, limit is %d", n, tree.pool.size)
+	}
+	checkDiskTree(t, tree, model)
+}
+
+// Abort恢复已经提交的page 丢弃本次操作新分配和修改的page 元数据回到上一次Commit
+func TestBufferPoolAbort(t *testing.T) {
+	bp := openTestPool(t, 4)
+	log, err := openWAL(filepath.Join(t.TempDir(), "pages.db-wal"), bp.pager.pageSize)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer log.close()
+	bp.log = log
+
+	id, page, _ := bp.NewPage()
+	copy(page[pageHeaderSize:], "committed")
+	bp.UnpinPage(id, true)
+	if err := bp.Commit(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	walSize, pageCount := log.size, bp.pager.PageCount()
+
+	// 写操作之外的读取不保存undo
+	page, _ = bp.FetchPage(id)
+	bp.UnpinPage(id, false)
+	if len(bp.saved) != 0 || bp.table[id].undo != nil {
+		t.Errorf("read outside of a write operation saved undo")
+	}
+
+	// 修改已经提交的page 分配新page 修改元数据 然后Abort
+	bp.Begin()
+	page, _ = bp.FetchPage(id)
+	copy(page[pageHeaderSize:], "half done")
+	bp.UnpinPage(id, true)
+	added, _, _ := bp.NewPage()
+	bp.UnpinPage(added, true)
+	bp.pager.meta.root = added
+	if err := bp.Abort(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if log.size != walSize || len(bp.held) != 0 {
+		t.Errorf("aborted pages written to wal")
+	}
+	if bp.pager.PageCount() != pageCount || bp.pager.meta.root != nilPage {
+		t.Errorf("meta not restored: %+v", bp.pager.meta)
+	}
+	if _, ok := bp.table[added]; ok {
+		t.Errorf("aborted new page still cached")
+	}
+	page, _ = bp.FetchPage(id)
+	if got := string(page[pageHeaderSize : pageHeaderSize+9]); got != "committed" {
+		t.Errorf("expected committed content and got %q", got)
+	}
+	bp.UnpinPage(id, false)
+
+	// Abort之后的操作正常提交 新page复用同一个PageID
+	bp.Begin()
+	again, _, _ := bp.NewPage()
+	bp.UnpinPage(again, true)
+	if err := bp.Commit(); err != nil || again != added {
+		t.Errorf("expected page %d after abort and got %d, %v", added, again, err)
+	}
+}
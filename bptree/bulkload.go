@@ -0,0 +1,155 @@
+package bptree
+
+import (
+	"fmt"
+	"iter"
+	"math"
+)
+
+// *********************** 批量构建 ***********************
+/*
+BulkLoad从已经排好序的数据自下而上构建整棵树,不需要逐个Insert(每次都要查重、从中间分裂):
+1. 按顺序把数据装入叶子节点,每个叶子装满fillFactor*maxLimit个key,同时串好Next/Prev链表。
+2. 对每一层的节点同样分组构建上一层,分隔key是右侧子树中最小的key,直到只剩一个节点作为根节点。
+3. 每层最后一个节点不足minLimit时,和前一个节点合并或者平分,保证构建完成后的树满足B+树的约束。
+*/
+
+// BulkLoad :用按key严格递增的数据构建树 树必须为空
+/*
+fillFactor取值(0,1],表示每个节点装入的key比例(不少于minLimit):
+只读或者很少修改的场景可以设置为1,之后有较多插入时留一些空间可以减少分裂。
+数据不是严格递增时返回错误,树保持为空。并发模式下构建期间独占整棵树,seq中不能调用这棵树的方法。
+*/
+func (t *Tree[K, V]) BulkLoad(seq iter.Seq2[K, V], fillFactor float64) error {
+	if fillFactor <= 0 || fillFactor > 1 {
+		return fmt.Errorf("%w: fill factor %v must be in (0, 1]", ErrInvalidArgument, fillFactor)
+	}
+	if t.concurrent {
+		t.cowLatch.Lock()
+		defer t.cowLatch.Unlock()
+	}
+	if t.Root != nil {
+		return ErrNotEmpty
+	}
+
+	fill := clamp(int(math.Ceil(fillFactor*float64(t.maxLimit))), max(t.minLimit, 1), t.maxLimit)
+
+	// 1.构建叶子节点 mins记录每个节点子树中最小的key
+	var level []*Node[K, V]
+	var mins []K
+	var leaf *Node[K, V]
+	for key, value := range seq {
+		if leaf != nil && t.compare(leaf.Keys[leaf.Count-1], key) >= 0 {
+			return fmt.Errorf("%w: %v after %v", ErrUnsorted, key, leaf.Keys[leaf.Count-1])
+		}
+		if leaf == nil || leaf.Count == fill {
+			next, _ := newLeaf[K, V]()
+			next.gen = t.gen
+			next.Keys = make([]K, 0, fill)
+			next.Pointers = make([]interface{}, 0, fill)
+			if leaf != nil {
+				leaf.Next = next
+				next.Prev = leaf
+			}
+			leaf = next
+			level = append(level, leaf)
+			mins = append(mins, key)
+		}
+
+		pointer, err := newRecord(value)
+		if err != nil {
+			return err
+		}
+		leaf.Keys = append(leaf.Keys, key)
+		leaf.Pointers = append(leaf.Pointers, pointer)
+		leaf.Count++
+	}
+	if leaf == nil {
+		return nil
+	}
+	level, mins = t.rebalanceLast(level, mins)
+	recountLevel(level)
+
+	// 2.逐层向上构建 直到只剩根节点
+	for len(level) > 1 {
+		var parents []*Node[K, V]
+		var parentMins []K
+		for i := 0; i < len(level); i += fill + 1 {
+			children := level[i:min(i+fill+1, len(level))]
+			n, _ := newNode[K, V]()
+			n.gen = t.gen
+			n.Keys = append(make([]K, 0, len(children)-1), mins[i+1:i+len(children)]...)
+			n.Pointers = make([]interface{}, 0, len(children))
+			for _, child := range children {
+				child.Parent = n
+				n.Pointers = append(n.Pointers, child)
+			}
+			n.Count = len(n.Keys)
+			parents = append(parents, n)
+			parentMins = append(parentMins, mins[i])
+		}
+		level, mins = t.rebalanceLast(parents, parentMins)
+		recountLevel(level)
+	}
+
+	t.Root = level[0]
+	return nil
+}
+
+// 同一层最后一个节点key不足时 与前一个节点合并或者平分
+/*
+非叶子节点之间的分隔key来自mins(右侧子树中最小的key),移动子节点时一起更新。
+*/
+func (t *Tree[K, V]) rebalanceLast(level []*Node[K, V], mins []K) ([]*Node[K, V], []K) {
+	if len(level) < 2 || level[len(level)-1].Count >= t.minLimit {
+		return level, mins
+	}
+	left, right := level[len(level)-2], level[len(level)-1]
+
+	// 合并成一个序列 非叶子节点中间补上right的分隔key
+	keys := append([]K{}, left.Keys...)
+	if !left.IsLeaf {
+		keys = append(keys, mins[len(mins)-1])
+	}
+	keys = append(keys, right.Keys...)
+	pointers := append(append([]interface{}{}, left.Pointers...), right.Pointers...)
+
+	if len(keys) <= t.maxLimit {
+		// 合并到left 丢弃right
+		left.Keys, left.Pointers, left.Count = keys, pointers, len(keys)
+		left.Next = nil
+		if !left.IsLeaf {
+			for _, p := range pointers {
+				p.(*Node[K, V]).Parent = left
+			}
+		}
+		return level[:len(level)-1], mins[:len(mins)-1]
+	}
+
+	// 平分 非叶子节点中间的key上移作为新的分隔key
+	split := len(keys) / 2
+	if left.IsLeaf {
+		left.Keys, right.Keys = keys[:split:split], keys[split:]
+		left.Pointers, right.Pointers = pointers[:split:split], pointers[split:]
+		mins[len(mins)-1] = right.Keys[0]
+	} else {
+		left.Keys, right.Keys = keys[:split:split], keys[split+1:]
+		left.Pointers, right.Pointers = pointers[:split+1:split+1], pointers[split+1:]
+		mins[len(mins)-1] = keys[split]
+		for _, p := range left.Pointers {
+			p.(*Node[K, V]).Parent = left
+		}
+		for _, p := range right.Pointers {
+			p.(*Node[K, V]).Parent = right
+		}
+	}
+	left.Count, right.Count = len(left.Keys), len(right.Keys)
+	return level, mins
+}
+
+// 自下而上逐层计算size
+func recountLevel[K any, V any](level []*Node[K, V]) {
+	for _, n := range level {
+		n.recount()
+	}
+}
@@ -0,0 +1,110 @@
+package bptree
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"testing"
+)
+
+// 0~n-1的有序数据
+func sortedSeq(n int) iter.Seq2[int, int] {
+	return func(yield func(int, int) bool) {
+		for key := 0; key < n; key++ {
+			if !yield(key, key*10) {
+				return
+			}
+		}
+	}
+}
+
+func TestBulkLoad(t *testing.T) {
+	for _, order := range []int{3, 4, 5, 8, 64} {
+		for _, fill := range []float64{0.5, 0.8, 1} {
+			for _, n := range []int{1, 2, 3, 7, 100, 1001, 5000} {
+				name := fmt.Sprintf("order %d fill %v n %d", order, fill, n)
+				tree := NewTree[int, int](WithOrder(order))
+				if err := tree.BulkLoad(sortedSeq(n), fill); err != nil {
+					t.Fatalf("%s: %s", name, err)
+				}
+
+				checkOccupancy(t, tree)
+				checkLeafChain(t, tree, n)
+				checkLinks(t, tree)
+				for key := 0; key < n; key++ {
+					if rec, err := tree.Find(key); err != nil || rec.Value != key*10 {
+						t.Fatalf("%s: find %d failed: %v", name, key, err)
+					}
+				}
+
+				// 构建之后可以继续插入
+				if err := tree.Insert(n, n*10); err != nil {
+					t.Fatalf("%s: %s", name, err)
+				}
+				checkLeafChain(t, tree, n+1)
+			}
+		}
+	}
+}
+
+// 装满的叶子节点比逐个插入少
+func TestBulkLoadPacked(t *testing.T) {
+	packed := NewTree[int, int](WithOrder(16))
+	_ = packed.BulkLoad(sortedSeq(10000), 1)
+	inserted := NewTree[int, int](WithOrder(16))
+	for key := 0; key < 10000; key++ {
+		_ = inserted.Insert(key, key)
+	}
+
+	// 每个叶子15个key
+	if leaves := countLeaves(packed); leaves != 667 {
+		t.Errorf("expected 667 leaves and got %d", leaves)
+	}
+	if a, b := countLeaves(packed), countLeaves(inserted); a >= b {
+		t.Errorf("expected fewer leaves after bulk load, got %d and %d", a, b)
+	}
+}
+
+func TestBulkLoadErrors(t *testing.T) {
+	tree := NewTree[int, int]()
+	unsorted := func(yield func(int, int) bool) {
+		for _, key := range []int{1, 2, 5, 5, 6} {
+			if !yield(key, key) {
+				return
+			}
+		}
+	}
+	if err := tree.BulkLoad(unsorted, 1); !errors.Is(err, ErrUnsorted) {
+		t.Errorf("expected ErrUnsorted and got %v", err)
+	}
+	if tree.Root != nil {
+		t.Errorf("expected empty tree after failed bulk load")
+	}
+	if err := tree.BulkLoad(sortedSeq(10), 0); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument and got %v", err)
+	}
+
+	// 空数据 树保持为空
+	if err := tree.BulkLoad(sortedSeq(0), 1); err != nil || tree.Root != nil {
+		t.Errorf("expected empty tree, %v", err)
+	}
+	_ = tree.Insert(1, 1)
+	if err := tree.BulkLoad(sortedSeq(10), 1); !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("expected ErrNotEmpty and got %v", err)
+	}
+}
+
+func TestBulkLoadConcurrent(t *testing.T) {
+	tree := NewTree[int, int](WithOrder(8), WithConcurrency())
+	if err := tree.BulkLoad(sortedSeq(3000), 0.7); err != nil {
+		t.Fatalf("%s", err)
+	}
+	snap := tree.Snapshot()
+	for key := 3000; key < 4000; key++ {
+		_ = tree.Insert(key, key)
+	}
+	if keys, _ := snap.FindRange(0, 5000); len(keys) != 3000 {
+		t.Errorf("expected 3000 keys in snapshot and got %d", len(keys))
+	}
+	checkLeafChain(t, tree, 4000)
+}
@@ -0,0 +1,82 @@
+package bptree
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Codec :key/value和字节之间的编解码 持久化到page时使用
+/*
+Append把v编码后追加到dst并返回新的切片,Decode从src还原出v(src指向page的缓冲区,只在调用期间有效,需要持有时自己拷贝)。
+编码结果不要求保持key的顺序,磁盘上的key比较仍然使用解码后的值和比较函数。
+*/
+type Codec[T any] interface {
+	Append(dst []byte, v T) []byte
+	Decode(src []byte) (T, error)
+}
+
+var errShortBuffer = errors.New("bptree: short buffer")
+
+// IntCodec :int按8字节大端编码
+type IntCodec struct{}
+
+func (IntCodec) Append(dst []byte, v int) []byte {
+	return binary.BigEndian.AppendUint64(dst, uint64(v))
+}
+
+func (IntCodec) Decode(src []byte) (int, error) {
+	if len(src) != 8 {
+		return 0, errShortBuffer
+	}
+	return int(binary.BigEndian.Uint64(src)), nil
+}
+
+// Int64Codec :int64按8字节大端编码
+type Int64Codec struct{}
+
+func (Int64Codec) Append(dst []byte, v int64) []byte {
+	return binary.BigEndian.AppendUint64(dst, uint64(v))
+}
+
+func (Int64Codec) Decode(src []byte) (int64, error) {
+	if len(src) != 8 {
+		return 0, errShortBuffer
+	}
+	return int64(binary.BigEndian.Uint64(src)), nil
+}
+
+// Uint64Codec :uint64按8字节大端编码
+type Uint64Codec struct{}
+
+func (Uint64Codec) Append(dst []byte, v uint64) []byte {
+	return binary.BigEndian.AppendUint64(dst, v)
+}
+
+func (Uint64Codec) Decode(src []byte) (uint64, error) {
+	if len(src) != 8 {
+		return 0, errShortBuffer
+	}
+	return binary.BigEndian.Uint64(src), nil
+}
+
+// StringCodec :string原样保存
+type StringCodec struct{}
+
+func (StringCodec) Append(dst []byte, v string) []byte {
+	return append(dst, v...)
+}
+
+func (StringCodec) Decode(src []byte) (string, error) {
+	return string(src), nil
+}
+
+// BytesCodec :[]byte原样保存
+type BytesCodec struct{}
+
+func (BytesCodec) Append(dst []byte, v []byte) []byte {
+	return append(dst, v...)
+}
+
+func (BytesCodec) Decode(src []byte) ([]byte, error) {
+	return append([]byte{}, src...), nil
+}
@@ -0,0 +1,451 @@
+package bptree
+
+import (
+	"errors"
+	"runtime"
+	"slices"
+)
+
+// 要修改的节点被快照共享 只在内部使用 表示需要独占整棵树重新执行
+var errShared = errors.New("bptree: node is shared with a snapshot")
+
+// *********************** 并发模式(latch crabbing) ***********************
+/*
+开启WithConcurrency后 Insert/Delete/Find/FindRange可以被多个goroutine同时调用:
+1. 读操作自上而下加读latch(crabbing): 先锁住子节点 再释放父节点。
+2. 写操作先乐观下降: 沿路径加读latch 只对叶子节点加写latch,
+   如果叶子节点是"安全"的(插入不会分裂 删除不会合并/借调)就直接修改,整个过程只有叶子节点加写latch。
+   路径上的读latch保持到修改结束,因为祖先节点的size(见rank.go)也要更新。
+3. 否则释放所有latch,从根节点重新悲观下降: 沿路径加写latch,确认可以修改并更新size之后,
+   释放最后一个安全节点之前的所有祖先,这样分裂/合并向上传播时涉及到的节点都已经被当前操作锁住。
+4. 叶子节点之间的横向latch(修改链表指针、范围查找)一律使用TryLock,
+   失败就释放全部latch重新开始,避免和自上而下的加锁顺序形成死锁。
+
+节点的Parent指针只会被持有其父节点写latch的操作修改,所以只在持有父节点latch时才读取Parent。
+
+有快照时(见snapshot.go)被共享的节点需要先复制,复制会修改父节点和叶子链表上相邻的节点,
+所以所有操作都持有cowLatch的读锁,写操作发现要修改的节点被共享时放弃latch,
+改为持有cowLatch的写锁按非并发模式执行。每条路径只需要复制一次,之后的操作仍然使用latch crabbing。
+PrintTree/PrintLeaves等调试方法不加latch,调用时需要保证没有并发的写操作。
+*/
+
+// latchSet :悲观写操作当前持有的写latch集合
+type latchSet[K any, V any] struct {
+	t     *Tree[K, V]
+	root  bool          // 是否持有rootLatch
+	nodes []*Node[K, V] // 持有写latch的节点(按加锁顺序)
+}
+
+// 悲观写操作从锁住rootLatch开始
+func (t *Tree[K, V]) lockRoot() *latchSet[K, V] {
+	t.rootLatch.Lock()
+	return &latchSet[K, V]{t: t, root: true}
+}
+
+func (s *latchSet[K, V]) lock(n *Node[K, V]) {
+	n.latch.Lock()
+	s.nodes = append(s.nodes, n)
+}
+
+// 横向加latch 不阻塞
+func (s *latchSet[K, V]) tryLock(n *Node[K, V]) bool {
+	if !n.latch.TryLock() {
+		return false
+	}
+	s.nodes = append(s.nodes, n)
+	return true
+}
+
+// n是安全节点 释放n之前加锁的所有latch(包括rootLatch) 之后加锁的节点都在n的下层
+func (s *latchSet[K, V]) releaseAncestors(n *Node[K, V]) {
+	if s.root {
+		s.t.rootLatch.Unlock()
+		s.root = false
+	}
+	for i, held := range s.nodes {
+		if held == n {
+			s.nodes = append(s.nodes[:0], s.nodes[i:]...)
+			return
+		}
+		held.latch.Unlock()
+	}
+}
+
+// 持有的节点中有被快照共享的节点 需要独占整棵树复制
+func (s *latchSet[K, V]) shared() bool {
+	for _, n := range s.nodes {
+		if s.t.shared(n) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *latchSet[K, V]) releaseAll() {
+	if s.root {
+		s.t.rootLatch.Unlock()
+		s.root = false
+	}
+	for _, held := range s.nodes {
+		held.latch.Unlock()
+	}
+	s.nodes = s.nodes[:0]
+}
+
+// 自上而下加读latch找到key所在的叶子节点 返回时叶子节点的读latch仍然持有
+func (t *Tree[K, V]) findLeafLatched(key K) *Node[K, V] {
+	t.rootLatch.RLock()
+	n := t.Root
+	if n == nil {
+		t.rootLatch.RUnlock()
+		return nil
+	}
+	n.latch.RLock()
+	t.rootLatch.RUnlock()
+
+	for !n.IsLeaf {
+		child, _ := n.Pointers[n.getChildIndex(key, t.compare)].(*Node[K, V])
+		child.latch.RLock()
+		n.latch.RUnlock()
+		n = child
+	}
+	return n
+}
+
+// 自上而下加读latch找到key所在的叶子节点 叶子节点加写latch
+/*
+与findLeafLatched不同,路径上祖先节点的读latch不释放:乐观写操作修改叶子节点时还要更新祖先的size,
+返回之后调用unlockPath释放。
+*/
+func (t *Tree[K, V]) lockPath(key K) (leaf *Node[K, V], ancestors []*Node[K, V]) {
+	t.rootLatch.RLock()
+	n := t.Root
+	if n == nil {
+		t.rootLatch.RUnlock()
+		return nil, nil
+	}
+	lockNode(n, n.IsLeaf)
+	t.rootLatch.RUnlock()
+
+	for !n.IsLeaf {
+		ancestors = append(ancestors, n)
+		n, _ = n.Pointers[n.getChildIndex(key, t.compare)].(*Node[K, V])
+		lockNode(n, n.IsLeaf)
+	}
+	return n, ancestors
+}
+
+func unlockPath[K any, V any](leaf *Node[K, V], ancestors []*Node[K, V]) {
+	leaf.latch.Unlock()
+	for _, n := range ancestors {
+		n.latch.RUnlock()
+	}
+}
+
+func lockNode[K any, V any](n *Node[K, V], exclusive bool) {
+	if exclusive {
+		n.latch.Lock()
+	} else {
+		n.latch.RLock()
+	}
+}
+
+// 删除一个key之后节点不需要合并/借调
+func (t *Tree[K, V]) deleteSafe(n *Node[K, V], isRoot bool) bool {
+	if isRoot {
+		// 根节点被删空时需要调整Root
+		return n.Count > 1
+	}
+	return n.Count > t.minLimit
+}
+
+// *********************** Find ***********************
+
+func (t *Tree[K, V]) concurrentFind(key K) *Record[V] {
+	t.cowLatch.RLock()
+	defer t.cowLatch.RUnlock()
+
+	leaf := t.findLeafLatched(key)
+	if leaf == nil {
+		return nil
+	}
+	defer leaf.latch.RUnlock()
+	return leaf.getRecord(key, t.compare)
+}
+
+// 并发范围查找:沿叶子链表向右横向crabbing 拿不到下一个叶子的latch就从上次的位置重新下降
+func (t *Tree[K, V]) concurrentFindRange(q RangeQuery[K]) ([]K, []*Record[V]) {
+	t.cowLatch.RLock()
+	defer t.cowLatch.RUnlock()
+
+	var n *Node[K, V]
+	if q.Lower.Kind == BoundUnbounded {
+		n = t.edgeLeafLatched(false)
+	} else {
+		n = t.findLeafLatched(q.Lower.Key)
+	}
+
+	c := newRangeCollector[K, V](&q)
+	var last *K // 最后一个处理过的key(包括被Offset跳过的)
+	for n != nil {
+		for i := range n.Keys {
+			key := n.Keys[i]
+			// 重新下降后 跳过已经处理过的key
+			if !q.afterLower(key, t.compare) || (last != nil && t.compare(key, *last) <= 0) {
+				continue
+			}
+			if !q.beforeUpper(key, t.compare) || !c.add(key, n.Pointers[i].(*Record[V])) {
+				n.latch.RUnlock()
+				return c.keys, c.records
+			}
+			last = &key
+		}
+
+		next := n.Next
+		if next == nil {
+			n.latch.RUnlock()
+			break
+		}
+		if next.latch.TryRLock() {
+			n.latch.RUnlock()
+			n = next
+			continue
+		}
+
+		// 横向加latch失败 释放后从最后一个key重新下降
+		n.latch.RUnlock()
+		runtime.Gosched()
+		if last != nil {
+			n = t.findLeafLatched(*last)
+		} else if q.Lower.Kind == BoundUnbounded {
+			n = t.edgeLeafLatched(false)
+		} else {
+			n = t.findLeafLatched(q.Lower.Key)
+		}
+	}
+
+	return c.keys, c.records
+}
+
+// *********************** Insert ***********************
+
+func (t *Tree[K, V]) concurrentInsert(key K, pointer *Record[V], replace bool) error {
+	if err := t.latchedInsert(key, pointer, replace); err != errShared {
+		return err
+	}
+
+	// 路径上有被快照共享的节点 独占整棵树复制
+	t.cowLatch.Lock()
+	defer t.cowLatch.Unlock()
+	return t.insert(key, pointer, replace)
+}
+
+func (t *Tree[K, V]) latchedInsert(key K, pointer *Record[V], replace bool) error {
+	t.cowLatch.RLock()
+	defer t.cowLatch.RUnlock()
+
+	// 1.乐观插入 只锁叶子节点
+	if done, err := t.optimisticInsert(key, pointer, replace); done {
+		return err
+	}
+
+	// 2.叶子节点需要分裂 悲观插入(横向latch获取失败时重来)
+	for {
+		if done, err := t.pessimisticInsert(key, pointer, replace); done {
+			return err
+		}
+		runtime.Gosched()
+	}
+}
+
+func (t *Tree[K, V]) optimisticInsert(key K, pointer *Record[V], replace bool) (bool, error) {
+	leaf, ancestors := t.lockPath(key)
+	if leaf == nil {
+		// 空树需要初始化Root
+		return false, nil
+	}
+	defer unlockPath(leaf, ancestors)
+
+	i := leaf.getKeyIndex(key, t.compare)
+	if i > -1 && !replace {
+		return true, keyError(ErrKeyExists, key)
+	}
+	if i == -1 && leaf.Count >= t.maxLimit {
+		return false, nil
+	}
+	if t.shared(leaf) || slices.ContainsFunc(ancestors, t.shared) {
+		return true, errShared
+	}
+	if i > -1 {
+		leaf.Pointers[i] = pointer
+		return true, nil
+	}
+	t.resize(leaf, 1)
+	return true, t.insertIntoNode(leaf, key, pointer)
+}
+
+func (t *Tree[K, V]) pessimisticInsert(key K, pointer *Record[V], replace bool) (bool, error) {
+	s := t.lockRoot()
+	defer s.releaseAll()
+
+	if t.Root == nil {
+		return true, t.initRoot(key, pointer)
+	}
+
+	// 插入不会分裂的节点是安全的 之上的祖先只需要更新size
+	var top *Node[K, V]
+	n := t.Root
+	s.lock(n)
+	if n.Count < t.maxLimit {
+		top = n
+	}
+	for !n.IsLeaf {
+		child, _ := n.Pointers[n.getChildIndex(key, t.compare)].(*Node[K, V])
+		s.lock(child)
+		if child.Count < t.maxLimit {
+			top = child
+		}
+		n = child
+	}
+
+	i := n.getKeyIndex(key, t.compare)
+	if i > -1 && !replace {
+		return true, keyError(ErrKeyExists, key)
+	}
+	if s.shared() {
+		return true, errShared
+	}
+	if i > -1 {
+		n.Pointers[i] = pointer
+		return true, nil
+	}
+	// 叶子分裂需要修改右邻叶子的Prev指针
+	if n.Count >= t.maxLimit && n.Next != nil && !s.tryLock(n.Next) {
+		return false, nil
+	}
+
+	t.resize(n, 1)
+	if top != nil {
+		s.releaseAncestors(top)
+	}
+	if n.Count < t.maxLimit {
+		return true, t.insertIntoNode(n, key, pointer)
+	}
+	return true, t.splitAndInsertIntoLeaf(n, key, pointer)
+}
+
+// *********************** Delete ***********************
+
+func (t *Tree[K, V]) concurrentDelete(key K) error {
+	if err := t.latchedDelete(key); err != errShared {
+		return err
+	}
+
+	// 路径上有被快照共享的节点 独占整棵树复制
+	t.cowLatch.Lock()
+	defer t.cowLatch.Unlock()
+	return t.delete(key)
+}
+
+func (t *Tree[K, V]) latchedDelete(key K) error {
+	t.cowLatch.RLock()
+	defer t.cowLatch.RUnlock()
+
+	// 1.乐观删除 只锁叶子节点
+	if done, err := t.optimisticDelete(key); done {
+		return err
+	}
+
+	// 2.叶子节点需要合并或借调 悲观删除(横向latch获取失败时重来)
+	for {
+		if done, err := t.pessimisticDelete(key); done {
+			return err
+		}
+		runtime.Gosched()
+	}
+}
+
+func (t *Tree[K, V]) optimisticDelete(key K) (bool, error) {
+	leaf, ancestors := t.lockPath(key)
+	if leaf == nil {
+		return true, keyError(ErrKeyNotFound, key)
+	}
+	defer unlockPath(leaf, ancestors)
+
+	i := leaf.getKeyIndex(key, t.compare)
+	if i == -1 {
+		return true, keyError(ErrKeyNotFound, key)
+	}
+	if !t.deleteSafe(leaf, len(ancestors) == 0) {
+		return false, nil
+	}
+	if t.shared(leaf) || slices.ContainsFunc(ancestors, t.shared) {
+		return true, errShared
+	}
+	t.resize(leaf, -1)
+	t.removeKeyFromNode(leaf, key, leaf.Pointers[i], i)
+	return true, nil
+}
+
+func (t *Tree[K, V]) pessimisticDelete(key K) (bool, error) {
+	s := t.lockRoot()
+	defer s.releaseAll()
+
+	n := t.Root
+	if n == nil {
+		return true, keyError(ErrKeyNotFound, key)
+	}
+
+	// 删除后不会合并/借调的节点是安全的 之上的祖先只需要更新size
+	var top *Node[K, V]
+	s.lock(n)
+	safe := t.deleteSafe(n, true)
+	if safe {
+		top = n
+	}
+	for !n.IsLeaf {
+		i := n.getChildIndex(key, t.compare)
+		child, _ := n.Pointers[i].(*Node[K, V])
+		s.lock(child)
+		if safe = t.deleteSafe(child, false); safe {
+			top = child
+		} else {
+			// 不安全的子节点会和相邻节点合并或借调 一起锁住(与getNeighbour的选择一致)
+			if i == 0 {
+				s.lock(n.Pointers[1].(*Node[K, V]))
+			} else {
+				s.lock(n.Pointers[i-1].(*Node[K, V]))
+			}
+		}
+		n = child
+	}
+
+	i := n.getKeyIndex(key, t.compare)
+	if i == -1 {
+		return true, keyError(ErrKeyNotFound, key)
+	}
+	if s.shared() {
+		return true, errShared
+	}
+	// 叶子节点合并时需要修改链表另一侧叶子的指针
+	if !safe && n.Parent != nil {
+		link := n.Next
+		if _, neighbourIndex := n.getNeighbour(); neighbourIndex == -1 {
+			link = n.Prev
+		}
+		if link != nil && !s.tryLock(link) {
+			return false, nil
+		}
+	}
+
+	t.resize(n, -1)
+	if top != nil {
+		s.releaseAncestors(top)
+	}
+	if safe {
+		t.removeKeyFromNode(n, key, n.Pointers[i], i)
+		return true, nil
+	}
+	t.deleteKey(n, key, n.Pointers[i], i)
+	return true, nil
+}
@@ -0,0 +1,185 @@
+package bptree
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// 并发模式的测试 需要配合 go test -race 运行
+
+func TestConcurrentInsert(t *testing.T) {
+	tree := NewTree[int, int](WithOrder(8), WithConcurrency())
+	workers, num := 8, 2000
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(w)))
+			for _, i := range r.Perm(num) {
+				key := i*workers + w
+				if err := tree.Insert(key, key); err != nil {
+					t.Errorf("insert %d: %s", key, err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	checkOccupancy(t, tree)
+	checkLeafChain(t, tree, workers*num)
+	for key := 0; key < workers*num; key++ {
+		if rec, err := tree.Find(key); err != nil || rec.Value != key {
+			t.Fatalf("find %d failed: %v", key, err)
+		}
+	}
+}
+
+// 同一个key被并发插入 只能有一次成功
+func TestConcurrentInsertSameKeys(t *testing.T) {
+	tree := NewTree[int, int](WithOrder(4), WithConcurrency())
+	var succeed int64
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(w)))
+			for _, key := range r.Perm(1000) {
+				if tree.Insert(key, w) == nil {
+					atomic.AddInt64(&succeed, 1)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if succeed != 1000 {
+		t.Errorf("expected 1000 successful inserts and got %d", succeed)
+	}
+	checkLeafChain(t, tree, 1000)
+}
+
+// 读写同时进行:已经插入的key一定能查到 范围查找的结果有序且不重复
+func TestConcurrentReadWrite(t *testing.T) {
+	tree := NewTree[int, int](WithOrder(6), WithConcurrency())
+	for key := 0; key < 1000; key++ {
+		_ = tree.Insert(key*2, key)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for key := 1000 + w; key < 5000; key += 4 {
+				_ = tree.Insert(key*2, key)
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(w)))
+			for i := 0; i < 2000; i++ {
+				key := r.Intn(1000)
+				if rec, err := tree.Find(key * 2); err != nil || rec.Value != key {
+					t.Errorf("find %d failed: %v", key*2, err)
+					return
+				}
+				keys, _ := tree.FindRange(key, key+200)
+				for j := 1; j < len(keys); j++ {
+					if keys[j] <= keys[j-1] {
+						t.Errorf("range result not sorted: %v", keys)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	checkOccupancy(t, tree)
+	checkLeafChain(t, tree, 5000)
+}
+
+func TestConcurrentDelete(t *testing.T) {
+	tree := NewTree[int, int](WithOrder(64), WithConcurrency())
+	workers, num := 4, 1500
+	for key := 0; key < num; key++ {
+		_ = tree.Insert(key, key)
+	}
+
+	// 每个goroutine删除自己负责的key中的一大半 同时有其他goroutine在查找
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for key := w; key < num; key += workers {
+				if key%10 == 0 {
+					continue
+				}
+				if err := tree.Delete(key); err != nil {
+					t.Errorf("delete %d: %s", key, err)
+					return
+				}
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			for key := w * 10; key < num; key += workers * 10 {
+				if _, err := tree.Find(key); err != nil {
+					t.Errorf("find %d failed: %v", key, err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	checkOccupancy(t, tree)
+	checkLeafChain(t, tree, num/10)
+	keys, _ := tree.FindRange(0, num)
+	for i, key := range keys {
+		if key != i*10 {
+			t.Fatalf("expected key %d and got %d", i*10, key)
+		}
+	}
+}
+
+// 检查叶子节点链表:key有序 Prev/Next互相对应 key总数正确
+func checkLeafChain[K any, V any](t *testing.T, tree *Tree[K, V], count int) {
+	t.Helper()
+	n := tree.Root
+	if n == nil {
+		if count != 0 {
+			t.Fatalf("expected %d keys and got empty tree", count)
+		}
+		return
+	}
+	for !n.IsLeaf {
+		n = n.Pointers[0].(*Node[K, V])
+	}
+
+	total := 0
+	var last *K
+	for prev := (*Node[K, V])(nil); n != nil; prev, n = n, n.Next {
+		if n.Prev != prev {
+			t.Fatalf("broken Prev pointer")
+		}
+		for i := range n.Keys {
+			if last != nil && tree.compare(*last, n.Keys[i]) >= 0 {
+				t.Fatalf("leaf keys are not sorted")
+			}
+			last = &n.Keys[i]
+		}
+		total += n.Count
+	}
+	if total != count {
+		t.Fatalf("expected %d keys and got %d", count, total)
+	}
+}
@@ -0,0 +1,248 @@
+package bptree
+
+import "runtime"
+
+// *********************** 游标 ***********************
+/*
+Cursor沿叶子节点的Next/Prev链表双向遍历,不需要像FindRange那样把结果复制到切片中。
+1. 非并发模式下游标直接保存当前的叶子节点和位置,修改tree之后需要重新Seek。
+2. 并发模式下游标不持有任何latch,只保存当前的key:每次移动都从根节点加读latch下降到当前key所在的叶子节点,
+   需要跨越叶子节点时横向TryRLock,失败就重新下降。其他goroutine的修改不会让游标失效,
+   游标总是移动到当前tree中的下一个(上一个)key。
+*/
+
+// Cursor :双向游标 新建的游标无效 需要先调用Seek/SeekFirst/SeekLast
+type Cursor[K any, V any] struct {
+	t      *Tree[K, V]
+	n      *Node[K, V] // 当前叶子节点(只在非并发模式下使用)
+	i      int         // 当前key在叶子节点中的位置
+	key    K
+	record *Record[V]
+	valid  bool
+}
+
+// Cursor :创建游标
+func (t *Tree[K, V]) Cursor() *Cursor[K, V] {
+	return &Cursor[K, V]{t: t}
+}
+
+// Valid :游标是否指向一个key
+func (c *Cursor[K, V]) Valid() bool {
+	return c.valid
+}
+
+// Key :当前key 游标无效时返回零值
+func (c *Cursor[K, V]) Key() K {
+	return c.key
+}
+
+// Value :当前value 游标无效时返回零值
+func (c *Cursor[K, V]) Value() V {
+	if !c.valid {
+		var zero V
+		return zero
+	}
+	return c.record.Value
+}
+
+// Seek :移动到第一个>=key的位置
+func (c *Cursor[K, V]) Seek(key K) bool {
+	t := c.t
+	if t.concurrent {
+		return c.set(t.latchedSeek(key, true, func(k K) bool { return t.compare(k, key) >= 0 }))
+	}
+
+	c.n = t.findLeaf(key)
+	if c.n != nil {
+		c.i = c.n.getInsertIndex(key, t.compare)
+	}
+	return c.forward()
+}
+
+// SeekFirst :移动到最小的key
+func (c *Cursor[K, V]) SeekFirst() bool {
+	if c.t.concurrent {
+		return c.set(c.t.latchedSeekEdge(true))
+	}
+
+	c.n, c.i = c.t.edgeLeaf(false), 0
+	return c.forward()
+}
+
+// SeekLast :移动到最大的key
+func (c *Cursor[K, V]) SeekLast() bool {
+	if c.t.concurrent {
+		return c.set(c.t.latchedSeekEdge(false))
+	}
+
+	c.n = c.t.edgeLeaf(true)
+	if c.n != nil {
+		c.i = c.n.Count - 1
+	}
+	return c.backward()
+}
+
+// Next :移动到下一个key 已经是最后一个时游标变为无效
+func (c *Cursor[K, V]) Next() bool {
+	if !c.valid {
+		return false
+	}
+	t := c.t
+	if t.concurrent {
+		key := c.key
+		return c.set(t.latchedSeek(key, true, func(k K) bool { return t.compare(k, key) > 0 }))
+	}
+
+	c.i++
+	return c.forward()
+}
+
+// Prev :移动到上一个key 已经是第一个时游标变为无效
+func (c *Cursor[K, V]) Prev() bool {
+	if !c.valid {
+		return false
+	}
+	t := c.t
+	if t.concurrent {
+		key := c.key
+		return c.set(t.latchedSeek(key, false, func(k K) bool { return t.compare(k, key) < 0 }))
+	}
+
+	c.i--
+	return c.backward()
+}
+
+// 当前位置超出叶子节点时移动到右侧叶子节点
+func (c *Cursor[K, V]) forward() bool {
+	for c.n != nil && c.i >= c.n.Count {
+		c.n, c.i = c.n.Next, 0
+	}
+	return c.load()
+}
+
+// 当前位置超出叶子节点时移动到左侧叶子节点
+func (c *Cursor[K, V]) backward() bool {
+	for c.n != nil && c.i < 0 {
+		c.n = c.n.Prev
+		if c.n != nil {
+			c.i = c.n.Count - 1
+		}
+	}
+	return c.load()
+}
+
+func (c *Cursor[K, V]) load() bool {
+	if c.n == nil {
+		return c.set(c.key, nil, false)
+	}
+	return c.set(c.n.Keys[c.i], c.n.Pointers[c.i].(*Record[V]), true)
+}
+
+func (c *Cursor[K, V]) set(key K, record *Record[V], ok bool) bool {
+	c.valid = ok
+	if ok {
+		c.key, c.record = key, record
+	} else {
+		var zero K
+		c.key, c.record, c.n = zero, nil, nil
+	}
+	return ok
+}
+
+// 最左(last为true时最右)的叶子节点
+func (t *Tree[K, V]) edgeLeaf(last bool) *Node[K, V] {
+	return subtreeEdge(t.Root, last)
+}
+
+// *********************** 并发模式下的游标定位 ***********************
+
+// 下降到key所在的叶子节点 forward时向右找第一个满足accept的key 否则向左找最后一个
+func (t *Tree[K, V]) latchedSeek(key K, forward bool, accept func(k K) bool) (K, *Record[V], bool) {
+	t.cowLatch.RLock()
+	defer t.cowLatch.RUnlock()
+
+	for {
+		n := t.findLeafLatched(key)
+		if k, r, found, ok := t.scanLatched(n, forward, accept); ok {
+			return k, r, found
+		}
+		runtime.Gosched()
+	}
+}
+
+// 最小(first为false时最大)的key
+func (t *Tree[K, V]) latchedSeekEdge(first bool) (K, *Record[V], bool) {
+	t.cowLatch.RLock()
+	defer t.cowLatch.RUnlock()
+
+	accept := func(K) bool { return true }
+	for {
+		n := t.edgeLeafLatched(!first)
+		if k, r, found, ok := t.scanLatched(n, first, accept); ok {
+			return k, r, found
+		}
+		runtime.Gosched()
+	}
+}
+
+// 自上而下加读latch找到最左(last为true时最右)的叶子节点 返回时持有叶子节点的读latch
+func (t *Tree[K, V]) edgeLeafLatched(last bool) *Node[K, V] {
+	t.rootLatch.RLock()
+	n := t.Root
+	if n == nil {
+		t.rootLatch.RUnlock()
+		return nil
+	}
+	n.latch.RLock()
+	t.rootLatch.RUnlock()
+
+	for !n.IsLeaf {
+		i := 0
+		if last {
+			i = n.Count
+		}
+		child, _ := n.Pointers[i].(*Node[K, V])
+		child.latch.RLock()
+		n.latch.RUnlock()
+		n = child
+	}
+	return n
+}
+
+// 从持有读latch的叶子节点n开始横向查找 返回时已经释放所有latch
+/*
+ok为false表示横向加latch失败,需要重新下降。
+*/
+func (t *Tree[K, V]) scanLatched(n *Node[K, V], forward bool, accept func(k K) bool) (key K, r *Record[V], found bool, ok bool) {
+	for n != nil {
+		if forward {
+			for i := 0; i < n.Count; i++ {
+				if accept(n.Keys[i]) {
+					key, r = n.Keys[i], n.Pointers[i].(*Record[V])
+					n.latch.RUnlock()
+					return key, r, true, true
+				}
+			}
+		} else {
+			for i := n.Count - 1; i >= 0; i-- {
+				if accept(n.Keys[i]) {
+					key, r = n.Keys[i], n.Pointers[i].(*Record[V])
+					n.latch.RUnlock()
+					return key, r, true, true
+				}
+			}
+		}
+
+		next := n.Next
+		if !forward {
+			next = n.Prev
+		}
+		if next != nil && !next.latch.TryRLock() {
+			n.latch.RUnlock()
+			return key, nil, false, false
+		}
+		n.latch.RUnlock()
+		n = next
+	}
+	return key, nil, false, true
+}
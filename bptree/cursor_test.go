@@ -0,0 +1,120 @@
+package bptree
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	for _, opts := range [][]Option{{WithOrder(4)}, {WithOrder(4), WithConcurrency()}} {
+		tree := NewTree[int, int](opts...)
+		c := tree.Cursor()
+		if c.SeekFirst() || c.SeekLast() || c.Seek(1) || c.Valid() {
+			t.Errorf("expected invalid cursor on empty tree")
+		}
+
+		for key := 0; key < 1000; key += 2 {
+			_ = tree.Insert(key, key*10)
+		}
+
+		// 正向遍历
+		keys := make([]int, 0)
+		for ok := c.SeekFirst(); ok; ok = c.Next() {
+			if c.Value() != c.Key()*10 {
+				t.Fatalf("unexpected value %d for key %d", c.Value(), c.Key())
+			}
+			keys = append(keys, c.Key())
+		}
+		if len(keys) != 500 || keys[0] != 0 || keys[499] != 998 {
+			t.Fatalf("unexpected forward keys, %d keys", len(keys))
+		}
+		if c.Valid() || c.Next() || c.Value() != 0 {
+			t.Errorf("expected invalid cursor after the last key")
+		}
+
+		// 反向遍历
+		back := make([]int, 0)
+		for ok := c.SeekLast(); ok; ok = c.Prev() {
+			back = append(back, c.Key())
+		}
+		for i := range back {
+			if back[i] != keys[len(keys)-1-i] {
+				t.Fatalf("unexpected backward keys")
+			}
+		}
+
+		// Seek到不存在的key 停在下一个key上
+		if !c.Seek(101) || c.Key() != 102 {
+			t.Errorf("expected 102 and got %d", c.Key())
+		}
+		if !c.Prev() || c.Key() != 100 || !c.Prev() || c.Key() != 98 {
+			t.Errorf("expected 98 and got %d", c.Key())
+		}
+		if !c.Seek(-5) || c.Key() != 0 || c.Prev() {
+			t.Errorf("expected cursor at first key")
+		}
+		if c.Seek(999) {
+			t.Errorf("expected invalid cursor after seeking past the last key")
+		}
+	}
+}
+
+// 并发修改时游标仍然按顺序返回 并且能看到所有没有被删除的key
+func TestCursorConcurrent(t *testing.T) {
+	tree := NewTree[int, int](WithOrder(64), WithConcurrency())
+	for key := 0; key < 3000; key += 3 {
+		_ = tree.Insert(key, key)
+	}
+
+	var wg sync.WaitGroup
+	for w := 1; w <= 2; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for key := w; key < 3000; key += 3 {
+				_ = tree.Insert(key, key)
+			}
+			for key := w; key < 3000; key += 3 {
+				_ = tree.Delete(key)
+			}
+		}(w)
+	}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			c := tree.Cursor()
+			seen := make([]int, 0)
+			last := -1
+			move, ok := c.Next, c.SeekFirst()
+			if r%2 == 1 {
+				move, ok, last = c.Prev, c.SeekLast(), 3000
+			}
+			for ; ok; ok = move() {
+				if (r%2 == 0 && c.Key() <= last) || (r%2 == 1 && c.Key() >= last) {
+					t.Errorf("cursor keys are out of order: %d after %d", c.Key(), last)
+					return
+				}
+				last = c.Key()
+				if last%3 == 0 {
+					seen = append(seen, last)
+				}
+			}
+			if len(seen) != 1000 {
+				t.Errorf("expected 1000 stable keys and got %d", len(seen))
+			}
+		}(r)
+	}
+	wg.Wait()
+
+	keys := make([]int, 0)
+	c := tree.Cursor()
+	for ok := c.SeekFirst(); ok; ok = c.Next() {
+		keys = append(keys, c.Key())
+	}
+	want, _ := tree.FindRange(0, 3000)
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("expected %d keys and got %d", len(want), len(keys))
+	}
+}
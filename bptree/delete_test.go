@@ -0,0 +1,130 @@
+package bptree
+
+import (
+	"sync"
+	"testing"
+)
+
+// 随机插入删除 与map对比 并检查树的结构
+func checkModel(t *testing.T, tree *Tree[int, int], ops, keyRange, validateEvery int) {
+	t.Helper()
+	r := testRand(t)
+	model := make(map[int]int)
+	for i := 1; i <= ops; i++ {
+		key := r.Intn(keyRange)
+		_, exists := model[key]
+		// 前半段插入多 后半段删除多 让树先长高再缩小
+		insert := r.Intn(10) < 7
+		if i > ops/2 {
+			insert = r.Intn(10) < 3
+		}
+
+		if insert {
+			err := tree.Insert(key, i)
+			if exists != (err != nil) {
+				t.Fatalf("insert %d: exists %v, err %v", key, exists, err)
+			}
+			if !exists {
+				model[key] = i
+			}
+		} else {
+			err := tree.Delete(key)
+			if exists != (err == nil) {
+				t.Fatalf("delete %d: exists %v, err %v", key, exists, err)
+			}
+			delete(model, key)
+		}
+
+		if i%validateEvery == 0 || i == ops {
+			if err := tree.Validate(); err != nil {
+				t.Fatalf("order %d after %d ops: %s", tree.order, i, err)
+			}
+			if tree.Len() != len(model) {
+				t.Fatalf("expected %d keys and got %d", len(model), tree.Len())
+			}
+		}
+	}
+
+	for key, value := range model {
+		if v, ok := tree.Get(key); !ok || v != value {
+			t.Fatalf("expected %d for key %d and got %d", value, key, v)
+		}
+	}
+	// 全部删除 最后树为空
+	for key := range model {
+		if err := tree.Delete(key); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+	if tree.Root != nil {
+		t.Errorf("expected empty tree")
+	}
+}
+
+func TestDeleteModel(t *testing.T) {
+	for _, order := range []int{3, 4, 5, 6, 7, 16} {
+		checkModel(t, NewTree[int, int](WithOrder(order)), 20000, 3000, 500)
+		checkModel(t, NewTree[int, int](WithOrder(order), WithConcurrency()), 5000, 1000, 500)
+	}
+}
+
+// 非叶子节点借调时 分隔key经过父节点旋转 子节点的Parent指向新的父节点
+func TestDeleteInternalBorrow(t *testing.T) {
+	tree := NewTree[int, int](WithOrder(3))
+	for key := 0; key < 64; key++ {
+		_ = tree.Insert(key, key)
+	}
+	for _, key := range []int{0, 1, 2, 3, 63, 62, 61, 60, 30, 31, 32} {
+		if err := tree.Delete(key); err != nil {
+			t.Fatalf("%s", err)
+		}
+		if err := tree.Validate(); err != nil {
+			t.Fatalf("delete %d: %s", key, err)
+		}
+	}
+	keys, _ := tree.FindRange(0, 100)
+	if len(keys) != 53 || keys[0] != 4 || keys[len(keys)-1] != 59 {
+		t.Errorf("unexpected keys %v", keys)
+	}
+}
+
+// 百万级数据 树足够高 每一层都会发生借调和合并
+func TestDeleteModelLarge(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large model test in short mode")
+	}
+	checkModel(t, NewTree[int, int](WithOrder(4)), 2000000, 1000000, 500000)
+}
+
+// 并发删除时非叶子节点的借调和合并
+func TestDeleteConcurrentSmallOrder(t *testing.T) {
+	tree := NewTree[int, int](WithOrder(4), WithConcurrency())
+	for key := 0; key < 4000; key++ {
+		_ = tree.Insert(key, key)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for key := w; key < 4000; key += 4 {
+				if key%5 == 0 {
+					continue
+				}
+				if err := tree.Delete(key); err != nil {
+					t.Errorf("delete %d: %s", key, err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if err := tree.Validate(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if tree.Len() != 800 {
+		t.Errorf("expected 800 keys and got %d", tree.Len())
+	}
+}
@@ -0,0 +1,149 @@
+package bptree
+
+// *********************** 范围删除 ***********************
+/*
+DeleteRange删除一个范围内的所有key,不需要逐个Delete(每次都可能引起一串合并):
+1. 找到范围内第一个key所在的叶子节点,如果整个叶子都在范围内,就向上找到整个子树都在范围内的最高祖先,
+   把这棵子树从叶子链表和父节点中摘除,父节点按删除一个子节点的方式借调或合并。
+2. 范围两端的叶子节点只有一部分key在范围内,逐个删除这些key。
+3. 重复直到范围内没有key,每次摘除的都是尽可能大的子树,所以只有范围两端的路径需要调整。
+DeleteIf把满足条件的连续key作为一个范围删除。
+并发模式下两者都独占整棵树(与BulkLoad相同)。
+*/
+
+// DeleteRange :删除lo<=key<hi的所有数据 返回删除的数量
+func (t *Tree[K, V]) DeleteRange(lo, hi K) int {
+	if t.concurrent {
+		t.cowLatch.Lock()
+		defer t.cowLatch.Unlock()
+	}
+	return t.deleteRange(&RangeQuery[K]{Lower: Included(lo), Upper: Excluded(hi)})
+}
+
+// DeleteIf :删除pred返回true的所有数据 返回删除的数量
+/*
+pred按key从小到大对每条数据调用一次,并发模式下调用时独占整棵树,pred中不能调用这棵树的方法。
+*/
+func (t *Tree[K, V]) DeleteIf(pred func(key K, value V) bool) int {
+	if t.concurrent {
+		t.cowLatch.Lock()
+		defer t.cowLatch.Unlock()
+	}
+
+	// 1.沿叶子链表找出满足条件的连续key [first, last]
+	var runs []RangeQuery[K]
+	inRun := false
+	for n := t.edgeLeaf(false); n != nil; n = n.Next {
+		for i, key := range n.Keys {
+			if !pred(key, n.Pointers[i].(*Record[V]).Value) {
+				inRun = false
+				continue
+			}
+			if inRun {
+				runs[len(runs)-1].Upper.Key = key
+			} else {
+				runs = append(runs, RangeQuery[K]{Lower: Included(key), Upper: Included(key)})
+				inRun = true
+			}
+		}
+	}
+
+	// 2.每段连续的key作为一个范围删除
+	removed := 0
+	for i := range runs {
+		removed += t.deleteRange(&runs[i])
+	}
+	return removed
+}
+
+// 删除q范围内的所有key 调用者保证没有并发的修改
+func (t *Tree[K, V]) deleteRange(q *RangeQuery[K]) int {
+	removed := 0
+	for {
+		leaf, i := t.firstInRange(q)
+		if leaf == nil {
+			return removed
+		}
+
+		// 范围两端的叶子节点 逐个删除
+		if !t.covered(leaf, q) {
+			leaf = t.writable(leaf)
+			t.resize(leaf, -1)
+			t.deleteKey(leaf, leaf.Keys[i], leaf.Pointers[i], i)
+			removed++
+			continue
+		}
+
+		// 整个子树都在范围内的最高祖先
+		n := leaf
+		for n.Parent != nil && t.covered(n.Parent, q) {
+			n = n.Parent
+		}
+		removed += int(n.subtreeSize())
+		t.dropSubtree(n)
+	}
+}
+
+// 范围内第一个key所在的叶子节点和位置 没有时返回nil
+func (t *Tree[K, V]) firstInRange(q *RangeQuery[K]) (*Node[K, V], int) {
+	var n *Node[K, V]
+	if q.Lower.Kind == BoundUnbounded {
+		n = t.edgeLeaf(false)
+	} else {
+		n = t.findLeaf(q.Lower.Key)
+	}
+
+	for ; n != nil; n = n.Next {
+		for i, key := range n.Keys {
+			if !q.afterLower(key, t.compare) {
+				continue
+			}
+			if !q.beforeUpper(key, t.compare) {
+				return nil, 0
+			}
+			return n, i
+		}
+	}
+	return nil, 0
+}
+
+// 子树中所有的key都在范围内
+func (t *Tree[K, V]) covered(n *Node[K, V], q *RangeQuery[K]) bool {
+	first, last := subtreeEdge(n, false), subtreeEdge(n, true)
+	return q.afterLower(first.Keys[0], t.compare) && q.beforeUpper(last.Keys[last.Count-1], t.compare)
+}
+
+// 把整个子树从树中摘除
+func (t *Tree[K, V]) dropSubtree(n *Node[K, V]) {
+	if n.Parent == nil {
+		t.Root = nil
+		return
+	}
+
+	// 1.从叶子链表中摘除子树的所有叶子
+	first, last := subtreeEdge(n, false), subtreeEdge(n, true)
+	if first.Prev != nil {
+		first.Prev.Next = last.Next
+	}
+	if last.Next != nil {
+		last.Next.Prev = first.Prev
+	}
+
+	// 2.从父节点删除指向子树的pointer和一个相邻的分隔key 父节点按需要借调或合并
+	parent := t.writable(n.Parent)
+	t.resize(parent, -n.subtreeSize())
+	keyIndex := max(parent.getPointerIndex(n)-1, 0)
+	t.deleteKey(parent, parent.Keys[keyIndex], n, keyIndex)
+}
+
+// 子树中最左(last为true时最右)的叶子节点
+func subtreeEdge[K any, V any](n *Node[K, V], last bool) *Node[K, V] {
+	for n != nil && !n.IsLeaf {
+		i := 0
+		if last {
+			i = n.Count
+		}
+		n, _ = n.Pointers[i].(*Node[K, V])
+	}
+	return n
+}
@@ -0,0 +1,111 @@
+package bptree
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// 随机范围删除 与排好序的切片对比 快照不受影响
+func TestDeleteRange(t *testing.T) {
+	for _, opts := range [][]Option{{WithOrder(3)}, {WithOrder(4)}, {WithOrder(7), WithConcurrency()}, {WithOrder(32)}} {
+		r := testRand(t)
+		tree := NewTree[int, int](opts...)
+		sorted := make([]int, 0)
+		for key := 0; key < 5000; key++ {
+			if r.Intn(4) > 0 {
+				_ = tree.Insert(key, key)
+				sorted = append(sorted, key)
+			}
+		}
+		snap := tree.Snapshot()
+		want, _ := snap.FindRange(0, 5000)
+
+		for len(sorted) > 0 {
+			lo := r.Intn(5200) - 100
+			hi := lo + r.Intn(1500)
+			i, j := sort.SearchInts(sorted, lo), sort.SearchInts(sorted, hi)
+			if n := tree.DeleteRange(lo, hi); n != j-i {
+				t.Fatalf("DeleteRange(%d, %d): expected %d and got %d", lo, hi, j-i, n)
+			}
+			sorted = append(sorted[:i], sorted[j:]...)
+
+			if err := tree.Validate(); err != nil {
+				t.Fatalf("DeleteRange(%d, %d): %s", lo, hi, err)
+			}
+			keys, _ := tree.FindRange(-1000, 10000)
+			if len(keys) != len(sorted) || (len(keys) > 0 && !reflect.DeepEqual(keys, sorted)) {
+				t.Fatalf("DeleteRange(%d, %d): expected %d keys and got %d", lo, hi, len(sorted), len(keys))
+			}
+		}
+		if tree.Root != nil {
+			t.Errorf("expected empty tree")
+		}
+		if keys, _ := snap.FindRange(0, 5000); !reflect.DeepEqual(keys, want) {
+			t.Errorf("snapshot changed after DeleteRange")
+		}
+		if tree.DeleteRange(0, 100) != 0 {
+			t.Errorf("expected nothing to delete in empty tree")
+		}
+	}
+}
+
+// 删除大范围时摘除整个子树 只有两端的叶子节点逐个删除key
+func TestDeleteRangeDropsSubtrees(t *testing.T) {
+	tree := NewTree[int, int](WithOrder(4))
+	for key := 0; key < 100000; key++ {
+		_ = tree.Insert(key, key)
+	}
+	leaves := countLeaves(tree)
+	if n := tree.DeleteRange(10, 99990); n != 99980 {
+		t.Fatalf("expected 99980 deleted and got %d", n)
+	}
+	if err := tree.Validate(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	keys, _ := tree.FindRange(0, 100000)
+	if len(keys) != 20 || keys[9] != 9 || keys[10] != 99990 {
+		t.Errorf("unexpected keys %v", keys)
+	}
+	if leaves < 10000 || countLeaves(tree) > 10 {
+		t.Errorf("expected leaves to be dropped: %d before, %d after", leaves, countLeaves(tree))
+	}
+}
+
+func TestDeleteIf(t *testing.T) {
+	for _, opts := range [][]Option{{WithOrder(4)}, {WithOrder(5), WithConcurrency()}} {
+		tree := NewTree[int, int](opts...)
+		for key := 0; key < 3000; key++ {
+			_ = tree.Insert(key, key%100)
+		}
+
+		// value<40的key 每100个中有连续的40个
+		calls := 0
+		n := tree.DeleteIf(func(key, value int) bool {
+			calls++
+			return value < 40
+		})
+		if n != 1200 || calls != 3000 {
+			t.Errorf("expected 1200 deleted with 3000 calls and got %d, %d", n, calls)
+		}
+		if err := tree.Validate(); err != nil {
+			t.Fatalf("%s", err)
+		}
+		for key := 0; key < 3000; key++ {
+			if _, ok := tree.Get(key); ok != (key%100 >= 40) {
+				t.Fatalf("unexpected presence of key %d", key)
+			}
+		}
+
+		// 不连续的key
+		if n := tree.DeleteIf(func(key, value int) bool { return key%2 == 0 }); n != 900 {
+			t.Errorf("expected 900 deleted and got %d", n)
+		}
+		if n := tree.DeleteIf(func(key, value int) bool { return true }); n != 900 || tree.Root != nil {
+			t.Errorf("expected empty tree and got %d deleted", n)
+		}
+		if err := tree.Validate(); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+}
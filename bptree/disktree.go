@@ -0,0 +1,613 @@
+package bptree
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// *********************** 磁盘B+树 ***********************
+/*
+DiskTree把每个节点序列化到Pager管理的一个page中,节点之间通过PageID引用,数据可以远大于内存并且重启后依然存在。
+插入/删除的算法和内存中的Tree一致(分裂、向父节点插入、借调、合并),区别是:
+1. 指针换成了PageID,每次访问节点都从page解码,修改之后再编码写回。page经过BufferPool缓存,dirty page在淘汰或Sync时才写入文件。
+2. 不保存Parent指针(否则分裂时要改写所有被移动的子节点page),父节点由下降时记录的路径得到。
+每次Insert/Delete修改的page先写入WAL(见wal.go),Sync之后操作就不会因为崩溃而丢失。
+DiskTree不支持并发,只能在单个goroutine中使用。
+*/
+
+// DiskTree :持久化到单个文件的B+树
+type DiskTree[K any, V any] struct {
+	pager   *Pager
+	pool    *BufferPool
+	log     *wal
+	compare func(a, b K) int // key比较函数
+	keys    Codec[K]         // key编解码
+	values  Codec[V]         // value编解码
+	treeLimits
+
+	leafBudget int    // 叶子节点中单个key+value编码后的字节上限
+	nodeBudget int    // 非叶子节点中单个key编码后的字节上限
+	scratch    []byte // 节点编码缓冲区
+	field      []byte // 字段编码缓冲区
+}
+
+// Open :打开或创建磁盘B+树 key为可排序类型(cmp.Ordered) 按自然顺序比较
+func Open[K cmp.Ordered, V any](path string, keys Codec[K], values Codec[V], opts ...Option) (*DiskTree[K, V], error) {
+	return OpenFunc(path, cmp.Compare[K], keys, values, opts...)
+}
+
+// OpenFunc :打开或创建磁盘B+树 使用自定义的key比较函数
+/*
+阶数和page大小在创建文件时确定并保存在meta页中,重新打开已有文件时以文件中的为准。
+打开时先重放WAL,恢复上次崩溃前已经写入WAL的操作。
+单个key/value编码后的大小受阶数和page大小限制(保证满节点也能放进一个page),超过时Insert返回错误。
+*/
+func OpenFunc[K any, V any](path string, compare func(a, b K) int, keys Codec[K], values Codec[V], opts ...Option) (*DiskTree[K, V], error) {
+	c := defaultConfig()
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	pager, err := OpenPager(path, c.pageSize)
+	if err != nil {
+		return nil, err
+	}
+	log, err := openWAL(path+"-wal", pager.pageSize)
+	if err != nil {
+		pager.Close()
+		return nil, err
+	}
+	fail := func(err error) (*DiskTree[K, V], error) {
+		log.close()
+		pager.Close()
+		return nil, err
+	}
+	if _, err := log.replay(pager); err != nil {
+		return fail(err)
+	}
+
+	created := pager.meta.order == 0
+	if !created {
+		c.order = pager.meta.order
+	}
+	// 新文件在保存阶数之前检查 无效的参数不能写入meta页
+	if err := c.validate(); err != nil {
+		return fail(err)
+	}
+	if c.order > maxDiskOrder {
+		// page头中的key数量是uint16
+		return fail(fmt.Errorf("%w %d: must be at most %d", ErrInvalidOrder, c.order, maxDiskOrder))
+	}
+	if nodeBudget(pager.pageSize, c.order) < 16 {
+		return fail(fmt.Errorf("%w %d: too large for page size %d", ErrInvalidOrder, c.order, pager.pageSize))
+	}
+	if created {
+		// 新文件 立即保存阶数
+		pager.meta.order = c.order
+		if err := pager.Sync(); err != nil {
+			return fail(err)
+		}
+	}
+
+	t := &DiskTree[K, V]{
+		pager:   pager,
+		compare: compare,
+		keys:    keys,
+		values:  values,
+		pool:    NewBufferPool(pager, c.poolSize),
+		log:     log,
+	}
+	t.pool.log = log
+	c.apply(&t.treeLimits)
+
+	t.leafBudget = (pager.pageSize - pageHeaderSize) / t.maxLimit
+	t.nodeBudget = nodeBudget(pager.pageSize, t.order)
+	return t, nil
+}
+
+// DiskTree的最大阶数 节点最多maxDiskOrder-1个key 可以用page头中的uint16表示
+const maxDiskOrder = math.MaxUint16
+
+// 非叶子节点中单个key编码后的字节上限 满节点的key和子节点PageID要放进一个page
+func nodeBudget(pageSize, order int) int {
+	return (pageSize - pageHeaderSize - 8*order) / (order - 1)
+}
+
+// Sync :把WAL刷盘 返回之后已经完成的操作在崩溃后可以恢复
+func (t *DiskTree[K, V]) Sync() error {
+	return t.log.sync()
+}
+
+// Checkpoint :把dirty page和元数据写入数据文件并刷盘 然后清空WAL
+func (t *DiskTree[K, V]) Checkpoint() error {
+	if err := t.pool.FlushAll(); err != nil {
+		return err
+	}
+	if err := t.pager.Sync(); err != nil {
+		return err
+	}
+	return t.log.truncate()
+}
+
+// Close :Checkpoint之后关闭文件
+func (t *DiskTree[K, V]) Close() error {
+	err := t.Checkpoint()
+	if cerr := t.log.close(); err == nil {
+		err = cerr
+	}
+	if cerr := t.pager.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// PoolStats :buffer pool的命中/未命中等统计信息
+func (t *DiskTree[K, V]) PoolStats() PoolStats {
+	return t.pool.Stats()
+}
+
+// *********************** page读写 ***********************
+
+// 节点解码后立即unpin 不会长时间占用frame
+func (t *DiskTree[K, V]) readNode(id PageID) (*diskNode[K, V], error) {
+	page, err := t.pool.FetchPage(id)
+	if err != nil {
+		return nil, err
+	}
+	defer t.pool.UnpinPage(id, false)
+	return t.decodeNode(id, page)
+}
+
+func (t *DiskTree[K, V]) writeNode(n *diskNode[K, V]) error {
+	page, err := t.pool.FetchPage(n.id)
+	if err != nil {
+		return err
+	}
+	err = t.encodeNode(n, page)
+	t.pool.UnpinPage(n.id, err == nil)
+	return err
+}
+
+// 分配一个新page作为节点 内容在writeNode时写入
+func (t *DiskTree[K, V]) newNode(isLeaf bool) (*diskNode[K, V], error) {
+	id, _, err := t.pool.NewPage()
+	if err != nil {
+		return nil, err
+	}
+	t.pool.UnpinPage(id, true)
+	return &diskNode[K, V]{id: id, isLeaf: isLeaf}, nil
+}
+
+func (t *DiskTree[K, V]) freeNode(n *diskNode[K, V]) error {
+	return t.pool.FreePage(n.id)
+}
+
+// 写操作结束 成功时把修改过的page写入WAL 失败时丢弃修改了一半的page和元数据
+func (t *DiskTree[K, V]) commit(err error) error {
+	if err != nil {
+		_ = t.pool.Abort()
+		return err
+	}
+	return t.pool.Commit()
+}
+
+// 检查key/value编码后能否放进节点
+func (t *DiskTree[K, V]) checkEntry(key K, value V) error {
+	t.field = t.keys.Append(t.field[:0], key)
+	keySize := uvarintLen(len(t.field)) + len(t.field)
+	t.field = t.values.Append(t.field[:0], value)
+	valueSize := uvarintLen(len(t.field)) + len(t.field)
+
+	if keySize > t.nodeBudget || keySize+valueSize > t.leafBudget {
+		return fmt.Errorf("%w: key/value for page size %d", ErrTooLarge, t.pager.pageSize)
+	}
+	return nil
+}
+
+// *********************** Insert部分 ***********************
+
+// Insert :插入key
+func (t *DiskTree[K, V]) Insert(key K, value V) error {
+	t.pool.Begin()
+	return t.commit(t.insert(key, value))
+}
+
+func (t *DiskTree[K, V]) insert(key K, value V) error {
+	if err := t.checkEntry(key, value); err != nil {
+		return err
+	}
+
+	path, index, err := t.findLeaf(key)
+	if err != nil {
+		return err
+	}
+	if path == nil {
+		return t.initRoot(key, value)
+	}
+
+	leaf := path[len(path)-1]
+	i, found := leaf.search(key, t.compare)
+	if found {
+		return keyError(ErrKeyExists, key)
+	}
+
+	leaf.keys = slices.Insert(leaf.keys, i, key)
+	leaf.values = slices.Insert(leaf.values, i, value)
+	if len(leaf.keys) <= t.maxLimit {
+		// 当前节点未排满 直接写回
+		return t.writeNode(leaf)
+	}
+	// 超出上限 分裂
+	return t.splitLeaf(path, index)
+}
+
+// 分裂叶子节点(已经临时插入了新key)
+func (t *DiskTree[K, V]) splitLeaf(path []*diskNode[K, V], index []int) error {
+	leaf := path[len(path)-1]
+	newLeaf, err := t.newNode(true)
+	if err != nil {
+		return err
+	}
+
+	newLeaf.keys = append([]K{}, leaf.keys[t.leafSplit:]...)
+	newLeaf.values = append([]V{}, leaf.values[t.leafSplit:]...)
+	leaf.keys = leaf.keys[:t.leafSplit]
+	leaf.values = leaf.values[:t.leafSplit]
+
+	// 双向链表处理
+	newLeaf.next = leaf.next
+	newLeaf.prev = leaf.id
+	if leaf.next != nilPage {
+		next, err := t.readNode(leaf.next)
+		if err != nil {
+			return err
+		}
+		next.prev = newLeaf.id
+		if err := t.writeNode(next); err != nil {
+			return err
+		}
+	}
+	leaf.next = newLeaf.id
+
+	if err := t.writeNode(leaf); err != nil {
+		return err
+	}
+	if err := t.writeNode(newLeaf); err != nil {
+		return err
+	}
+	return t.insertIntoParent(path[:len(path)-1], index, leaf.id, newLeaf.keys[0], newLeaf.id)
+}
+
+// 分裂后 插入父节点(path是被分裂节点的祖先路径)
+func (t *DiskTree[K, V]) insertIntoParent(path []*diskNode[K, V], index []int, left PageID, key K, right PageID) error {
+	// 没有父节点 说明是根节点分裂 则拉高为新的根节点
+	if len(path) == 0 {
+		return t.insertIntoNewRoot(left, key, right)
+	}
+
+	parent := path[len(path)-1]
+	i := index[len(path)-1]
+	parent.keys = slices.Insert(parent.keys, i, key)
+	parent.children = slices.Insert(parent.children, i+1, right)
+	if len(parent.keys) <= t.maxLimit {
+		return t.writeNode(parent)
+	}
+	return t.splitNode(path, index)
+}
+
+// 分裂非叶子节点(已经临时插入了新key)
+func (t *DiskTree[K, V]) splitNode(path []*diskNode[K, V], index []int) error {
+	n := path[len(path)-1]
+	newNode, err := t.newNode(false)
+	if err != nil {
+		return err
+	}
+
+	// 往parent插入的key 子节点不保留
+	key := n.keys[t.nodeSplit]
+	newNode.keys = append([]K{}, n.keys[t.nodeSplit+1:]...)
+	newNode.children = append([]PageID{}, n.children[t.nodeSplit+1:]...)
+	n.keys = n.keys[:t.nodeSplit]
+	n.children = n.children[:t.nodeSplit+1]
+
+	if err := t.writeNode(n); err != nil {
+		return err
+	}
+	if err := t.writeNode(newNode); err != nil {
+		return err
+	}
+	return t.insertIntoParent(path[:len(path)-1], index, n.id, key, newNode.id)
+}
+
+// 根节点分裂：需要创建一个新root
+func (t *DiskTree[K, V]) insertIntoNewRoot(left PageID, key K, right PageID) error {
+	root, err := t.newNode(false)
+	if err != nil {
+		return err
+	}
+	root.keys = []K{key}
+	root.children = []PageID{left, right}
+	if err := t.writeNode(root); err != nil {
+		return err
+	}
+	t.pager.meta.root = root.id
+	return nil
+}
+
+// 初始化根节点
+func (t *DiskTree[K, V]) initRoot(key K, value V) error {
+	root, err := t.newNode(true)
+	if err != nil {
+		return err
+	}
+	root.keys = []K{key}
+	root.values = []V{value}
+	if err := t.writeNode(root); err != nil {
+		return err
+	}
+	t.pager.meta.root = root.id
+	return nil
+}
+
+// *********************** Delete部分 ***********************
+
+// Delete :删除key
+func (t *DiskTree[K, V]) Delete(key K) error {
+	t.pool.Begin()
+	return t.commit(t.delete(key))
+}
+
+func (t *DiskTree[K, V]) delete(key K) error {
+	path, index, err := t.findLeaf(key)
+	if err != nil {
+		return err
+	}
+	if path == nil {
+		return keyError(ErrKeyNotFound, key)
+	}
+
+	leaf := path[len(path)-1]
+	i, found := leaf.search(key, t.compare)
+	if !found {
+		return keyError(ErrKeyNotFound, key)
+	}
+
+	leaf.keys = slices.Delete(leaf.keys, i, i+1)
+	leaf.values = slices.Delete(leaf.values, i, i+1)
+	return t.deleteKey(path, index)
+}
+
+// deleteKey :节点删除key之后的调整 需要向上递归(path的最后一个是被删除key的节点)
+func (t *DiskTree[K, V]) deleteKey(path []*diskNode[K, V], index []int) error {
+	n := path[len(path)-1]
+
+	// 删除的是root节点的key
+	if len(path) == 1 {
+		return t.adjustRoot(n)
+	}
+
+	// 删除之后node中的key数量合理 处理结束
+	if len(n.keys) >= t.minLimit {
+		return t.writeNode(n)
+	}
+
+	// 首节点的相邻是右节点 其他的相邻是左节点
+	parent := path[len(path)-2]
+	i := index[len(path)-2]
+	var left, right *diskNode[K, V]
+	var err error
+	if i == 0 {
+		left = n
+		right, err = t.readNode(parent.children[1])
+	} else {
+		left, err = t.readNode(parent.children[i-1])
+		right = n
+	}
+	if err != nil {
+		return err
+	}
+
+	// left和right在父节点中的分隔key
+	sepIndex := i - 1
+	if i == 0 {
+		sepIndex = 0
+	}
+
+	// 如果加和数量合理就合并 不合理就借调
+	size := len(left.keys) + len(right.keys)
+	if !n.isLeaf {
+		size++ // 非叶子节点合并时 父节点的key要拉下来
+	}
+	if size <= t.maxLimit {
+		return t.mergeToNode(path[:len(path)-1], index, left, right, sepIndex)
+	}
+	return t.borrowFromNode(parent, left, right, sepIndex, n == left)
+}
+
+// mergeToNode ：把right合并到left 然后从父节点删除分隔key和right(path的最后一个是父节点)
+func (t *DiskTree[K, V]) mergeToNode(path []*diskNode[K, V], index []int, left, right *diskNode[K, V], sepIndex int) error {
+	parent := path[len(path)-1]
+
+	if left.isLeaf {
+		left.keys = append(left.keys, right.keys...)
+		left.values = append(left.values, right.values...)
+		// 双向链表维护
+		left.next = right.next
+		if right.next != nilPage {
+			next, err := t.readNode(right.next)
+			if err != nil {
+				return err
+			}
+			next.prev = left.id
+			if err := t.writeNode(next); err != nil {
+				return err
+			}
+		}
+	} else {
+		// 非叶子节点 父节点的分隔key拉下来
+		left.keys = append(append(left.keys, parent.keys[sepIndex]), right.keys...)
+		left.children = append(left.children, right.children...)
+	}
+
+	if err := t.writeNode(left); err != nil {
+		return err
+	}
+	if err := t.freeNode(right); err != nil {
+		return err
+	}
+
+	// 递归删除父节点的key和pointer
+	parent.keys = slices.Delete(parent.keys, sepIndex, sepIndex+1)
+	parent.children = slices.Delete(parent.children, sepIndex+1, sepIndex+2)
+	return t.deleteKey(path, index)
+}
+
+// borrowFromNode ：从相邻节点借一个key 并更新父节点的分隔key
+/*
+fromRight为true时是left从right借 否则是right从left借
+*/
+func (t *DiskTree[K, V]) borrowFromNode(parent, left, right *diskNode[K, V], sepIndex int, fromRight bool) error {
+	if fromRight {
+		// 左从右借 右边第一个给左边最后一个
+		if left.isLeaf {
+			left.keys = append(left.keys, right.keys[0])
+			left.values = append(left.values, right.values[0])
+			right.keys = slices.Delete(right.keys, 0, 1)
+			right.values = slices.Delete(right.values, 0, 1)
+			parent.keys[sepIndex] = right.keys[0]
+		} else {
+			// 父节点的key转下来 右边第一个key转上去
+			left.keys = append(left.keys, parent.keys[sepIndex])
+			left.children = append(left.children, right.children[0])
+			parent.keys[sepIndex] = right.keys[0]
+			right.keys = slices.Delete(right.keys, 0, 1)
+			right.children = slices.Delete(right.children, 0, 1)
+		}
+	} else {
+		// 右从左借 左边最后一个给右边第一个
+		last := len(left.keys) - 1
+		if left.isLeaf {
+			right.keys = slices.Insert(right.keys, 0, left.keys[last])
+			right.values = slices.Insert(right.values, 0, left.values[last])
+			left.keys = left.keys[:last]
+			left.values = left.values[:last]
+			parent.keys[sepIndex] = right.keys[0]
+		} else {
+			right.keys = slices.Insert(right.keys, 0, parent.keys[sepIndex])
+			right.children = slices.Insert(right.children, 0, left.children[last+1])
+			parent.keys[sepIndex] = left.keys[last]
+			left.keys = left.keys[:last]
+			left.children = left.children[:last+1]
+		}
+	}
+
+	if err := t.writeNode(left); err != nil {
+		return err
+	}
+	if err := t.writeNode(right); err != nil {
+		return err
+	}
+	return t.writeNode(parent)
+}
+
+// root中的key被删之后,重新调整root
+func (t *DiskTree[K, V]) adjustRoot(root *diskNode[K, V]) error {
+	// root还剩key 写回即可
+	if len(root.keys) > 0 {
+		return t.writeNode(root)
+	}
+
+	if root.isLeaf {
+		// 被删空的root还是leaf 说明树空了
+		t.pager.meta.root = nilPage
+	} else {
+		// root被删空 说明之前只有一个key 子节点设置成新root即可
+		t.pager.meta.root = root.children[0]
+	}
+	return t.freeNode(root)
+}
+
+// *********************** Find部分 ***********************
+
+// Find :查找key key不存在时返回ErrKeyNotFound
+func (t *DiskTree[K, V]) Find(key K) (*Record[V], error) {
+	value, ok, err := t.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, keyError(ErrKeyNotFound, key)
+	}
+	return &Record[V]{Value: value}, nil
+}
+
+// Get :查找key的value 不存在时ok为false 只在读取文件出错时返回错误
+func (t *DiskTree[K, V]) Get(key K) (value V, ok bool, err error) {
+	path, _, err := t.findLeaf(key)
+	if err != nil || path == nil {
+		return value, false, err
+	}
+
+	leaf := path[len(path)-1]
+	i, found := leaf.search(key, t.compare)
+	if !found {
+		return value, false, nil
+	}
+	return leaf.values[i], true, nil
+}
+
+// FindRange :范围查找 返回[keyMin, keyMax]之间的key和数据
+func (t *DiskTree[K, V]) FindRange(keyMin K, keyMax K) ([]K, []*Record[V], error) {
+	path, _, err := t.findLeaf(keyMin)
+	if err != nil || path == nil {
+		return nil, nil, err
+	}
+
+	keys := make([]K, 0)
+	records := make([]*Record[V], 0)
+
+	n := path[len(path)-1]
+	i, _ := n.search(keyMin, t.compare)
+	for {
+		for ; i < len(n.keys); i++ {
+			if t.compare(n.keys[i], keyMax) > 0 {
+				return keys, records, nil
+			}
+			keys = append(keys, n.keys[i])
+			records = append(records, &Record[V]{Value: n.values[i]})
+		}
+
+		if n.next == nilPage {
+			return keys, records, nil
+		}
+		if n, err = t.readNode(n.next); err != nil {
+			return nil, nil, err
+		}
+		i = 0
+	}
+}
+
+// 自上而下读取page 找到key对应的leaf
+/*
+返回下降路径path(path[0]是根节点 最后一个是叶子) 和每一层选择的子节点index(index[i]是path[i+1]在path[i]中的位置),
+空树时path为nil。
+*/
+func (t *DiskTree[K, V]) findLeaf(key K) (path []*diskNode[K, V], index []int, err error) {
+	for id := t.pager.meta.root; id != nilPage; {
+		n, err := t.readNode(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		path = append(path, n)
+		if n.isLeaf {
+			return path, index, nil
+		}
+
+		i := n.childIndex(key, t.compare)
+		index = append(index, i)
+		id = n.children[i]
+	}
+	return nil, nil, nil
+}
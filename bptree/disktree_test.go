@@ -0,0 +1,348 @@
+package bptree
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func openTestDiskTree(t *testing.T, path string, opts ...Option) *DiskTree[int, string] {
+	t.Helper()
+	tree, err := Open[int, string](path, IntCodec{}, StringCodec{}, opts...)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	return tree
+}
+
+func TestDiskTreeInsertAndFind(t *testing.T) {
+	tree := openTestDiskTree(t, filepath.Join(t.TempDir(), "tree.db"), WithOrder(8))
+	defer tree.Close()
+
+	r := testRand(t)
+	keys := r.Perm(3000)
+	for _, key := range keys {
+		if err := tree.Insert(key, fmt.Sprint(key)); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+	if err := tree.Insert(keys[0], "again"); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+
+	for _, key := range keys {
+		rec, err := tree.Find(key)
+		if err != nil || rec.Value != fmt.Sprint(key) {
+			t.Fatalf("find %d failed: %v", key, err)
+		}
+	}
+	if _, err := tree.Find(-1); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+
+	found, records, err := tree.FindRange(100, 199)
+	if err != nil || len(found) != 100 {
+		t.Fatalf("expected 100 keys and got %d, %v", len(found), err)
+	}
+	for i, key := range found {
+		if key != 100+i || records[i].Value != fmt.Sprint(key) {
+			t.Fatalf("unexpected key %d at %d", key, i)
+		}
+	}
+}
+
+// 关闭后重新打开 数据和配置都还在
+func TestDiskTreeReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.db")
+	tree := openTestDiskTree(t, path, WithOrder(16), WithPageSize(1024))
+	for key := 0; key < 2000; key++ {
+		_ = tree.Insert(key, fmt.Sprint(key))
+	}
+	if err := tree.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// 重新打开时传入的配置不影响已有文件
+	tree = openTestDiskTree(t, path, WithOrder(5))
+	defer tree.Close()
+	if tree.Order() != 16 || tree.pager.PageSize() != 1024 {
+		t.Errorf("expected order 16 and page size 1024, got %d and %d", tree.Order(), tree.pager.PageSize())
+	}
+
+	keys, _, err := tree.FindRange(0, 2000)
+	if err != nil || len(keys) != 2000 {
+		t.Fatalf("expected 2000 keys and got %d, %v", len(keys), err)
+	}
+	if rec, err := tree.Find(1234); err != nil || rec.Value != "1234" {
+		t.Errorf("find 1234 failed: %v", err)
+	}
+}
+
+// 随机插入删除 与map对比
+func TestDiskTreeDelete(t *testing.T) {
+	for _, order := range []int{3, 4, 7, 32} {
+		path := filepath.Join(t.TempDir(), fmt.Sprintf("tree-%d.db", order))
+		tree := openTestDiskTree(t, path, WithOrder(order))
+		model := make(map[int]string)
+		r := testRand(t)
+
+		for i := 0; i < 6000; i++ {
+			key := r.Intn(1000)
+			if r.Intn(3) > 0 {
+				err := tree.Insert(key, fmt.Sprint(key))
+				if _, ok := model[key]; ok != (err != nil) {
+					t.Fatalf("order %d: insert %d: %v", order, key, err)
+				}
+				model[key] = fmt.Sprint(key)
+			} else {
+				err := tree.Delete(key)
+				if _, ok := model[key]; ok != (err == nil) {
+					t.Fatalf("order %d: delete %d: %v", order, key, err)
+				}
+				delete(model, key)
+			}
+		}
+		checkDiskTree(t, tree, model)
+
+		// 全部删除后树为空 page被回收 再次插入同样的数据不需要新的page
+		for key := range model {
+			if err := tree.Delete(key); err != nil {
+				t.Fatalf("order %d: %s", order, err)
+			}
+		}
+		for key := 0; key < 1000; key++ {
+			_ = tree.Insert(key, fmt.Sprint(key))
+		}
+		pages := tree.pager.PageCount()
+		for key := 0; key < 1000; key++ {
+			if err := tree.Delete(key); err != nil {
+				t.Fatalf("order %d: %s", order, err)
+			}
+		}
+		if tree.pager.meta.root != nilPage {
+			t.Errorf("order %d: expected empty tree", order)
+		}
+		for key := 0; key < 1000; key++ {
+			_ = tree.Insert(key, fmt.Sprint(key))
+		}
+		if tree.pager.PageCount() > pages {
+			t.Errorf("order %d: expected freed pages to be reused, %d -> %d", order, pages, tree.pager.PageCount())
+		}
+		tree.Close()
+	}
+}
+
+func TestDiskTreeStringKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.db")
+	tree, err := Open[string, []byte](path, StringCodec{}, BytesCodec{}, WithOrder(6), WithPageSize(512))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer tree.Close()
+
+	words := strings.Fields("the quick brown fox jumps over a lazy dog and then runs into the forest far away")
+	for _, w := range words {
+		_ = tree.Insert(w, []byte(strings.ToUpper(w)))
+	}
+
+	keys, records, err := tree.FindRange("b", "l")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	want := []string{"brown", "dog", "far", "forest", "fox", "into", "jumps"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("expected %v and got %v", want, keys)
+	}
+	if string(records[0].Value) != "BROWN" {
+		t.Errorf("expected BROWN and got %s", records[0].Value)
+	}
+
+	// 超过page容量的key/value
+	if err := tree.Insert("huge", make([]byte, 200)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge and got %v", err)
+	}
+}
+
+func TestDiskTreeOrderTooLarge(t *testing.T) {
+	_, err := Open[int, string](filepath.Join(t.TempDir(), "tree.db"), IntCodec{}, StringCodec{},
+		WithOrder(300), WithPageSize(1024))
+	if !errors.Is(err, ErrInvalidOrder) {
+		t.Errorf("expected ErrInvalidOrder and got %v", err)
+	}
+}
+
+// 阶数超过page头中uint16能表示的key数量 即使page足够大也拒绝
+func TestDiskTreeOrderAboveUint16(t *testing.T) {
+	_, err := Open[int, string](filepath.Join(t.TempDir(), "tree.db"), IntCodec{}, StringCodec{},
+		WithOrder(70000), WithPageSize(1<<21))
+	if !errors.Is(err, ErrInvalidOrder) {
+		t.Errorf("expected ErrInvalidOrder and got %v", err)
+	}
+}
+
+// 创建文件时参数无效 不保存到meta页 之后用有效的参数可以正常打开
+func TestDiskTreeInvalidOrderNotSaved(t *testing.T) {
+	for _, opts := range [][]Option{{WithOrder(2)}, {WithOrder(400), WithPageSize(512)}} {
+		path := filepath.Join(t.TempDir(), "tree.db")
+		if _, err := Open[int, string](path, IntCodec{}, StringCodec{}, opts...); !errors.Is(err, ErrInvalidOrder) {
+			t.Fatalf("expected ErrInvalidOrder and got %v", err)
+		}
+		tree := openTestDiskTree(t, path)
+		if tree.Order() != ORDER {
+			t.Errorf("expected order %d and got %d", ORDER, tree.Order())
+		}
+		if err := tree.Insert(1, "1"); err != nil {
+			t.Errorf("%s", err)
+		}
+		tree.Close()
+	}
+}
+
+// 页内容被破坏时返回错误而不是错误的数据
+func TestDiskTreeCorruptPage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.db")
+	tree := openTestDiskTree(t, path, WithPageSize(512))
+	for key := 0; key < 100; key++ {
+		_ = tree.Insert(key, fmt.Sprint(key))
+	}
+	tree.Close()
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	_, _ = f.WriteAt([]byte{0xff, 0xff}, 512+100)
+	f.Close()
+
+	tree = openTestDiskTree(t, path)
+	defer tree.Close()
+	if _, _, err := tree.FindRange(0, 100); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected ErrCorrupt and got %v", err)
+	}
+}
+
+// 检查磁盘树中的数据和model一致 并且叶子链表前后指针正确
+func checkDiskTree(t *testing.T, tree *DiskTree[int, string], model map[int]string) {
+	t.Helper()
+	want := make([]int, 0, len(model))
+	for key := range model {
+		want = append(want, key)
+	}
+	sort.Ints(want)
+
+	keys, _, err := tree.FindRange(-1, 1<<30)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(keys) != len(want) || (len(keys) > 0 && !reflect.DeepEqual(keys, want)) {
+		t.Fatalf("expected %d keys and got %d", len(want), len(keys))
+	}
+
+	// 反向沿Prev遍历
+	path, _, err := tree.findLeaf(1 << 30)
+	if err != nil || path == nil {
+		return
+	}
+	count := 0
+	for n := path[len(path)-1]; ; {
+		count += len(n.keys)
+		if n.prev == nilPage {
+			break
+		}
+		if n, err = tree.readNode(n.prev); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+	if count != len(want) {
+		t.Fatalf("expected %d keys walking backward and got %d", len(want), count)
+	}
+}
+
+// 分裂到一半读取兄弟节点失败 修改了一半的page和元数据不写入WAL 崩溃恢复后与失败前一致
+func TestDiskTreeAbortOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.db")
+	tree := openTestDiskTree(t, path, WithOrder(4), WithPageSize(512))
+	for key := 0; key < 1000; key += 10 {
+		_ = tree.Insert(key, fmt.Sprint(key))
+	}
+
+	// 把key所在的叶子节点插满 然后破坏它的下一个叶子节点
+	leaf := func(key int) *diskNode[int, string] {
+		path, _, _ := tree.findLeaf(key)
+		return path[len(path)-1]
+	}
+	first := leaf(500).keys[0]
+	for key := first + 1; len(leaf(first).keys) < tree.maxLimit; key++ {
+		_ = tree.Insert(key, fmt.Sprint(key))
+	}
+	next := leaf(first).next
+	if err := tree.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	_, _ = f.WriteAt([]byte{0xff, 0xff}, int64(next)*512+100)
+	f.Close()
+
+	tree = openTestDiskTree(t, path)
+	pageCount, walSize := tree.pager.PageCount(), tree.log.size
+	if err := tree.Insert(first+9, ""); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("expected ErrCorrupt and got %v", err)
+	}
+	if tree.pager.PageCount() != pageCount || tree.log.size != walSize {
+		t.Errorf("failed insert changed page count %d -> %d, wal %d -> %d",
+			pageCount, tree.pager.PageCount(), walSize, tree.log.size)
+	}
+	if value, _, err := tree.Get(first); err != nil || value != fmt.Sprint(first) {
+		t.Errorf("get %d after failed insert: %q %v", first, value, err)
+	}
+
+	// 之后成功的操作写入WAL 崩溃恢复后不包括失败的修改
+	if err := tree.Insert(-1, "-1"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := tree.Sync(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	crashDiskTree(tree)
+	tree = openTestDiskTree(t, path)
+	defer tree.Close()
+	if tree.pager.PageCount() != pageCount {
+		t.Errorf("expected %d pages after recovery and got %d", pageCount, tree.pager.PageCount())
+	}
+	if value, ok, err := tree.Get(-1); err != nil || !ok || value != "-1" {
+		t.Errorf("get -1 after recovery: %q %v %v", value, ok, err)
+	}
+}
+
+// 只读操作命中已经提交但还没有写回的page时不复制page
+func TestDiskTreeReadNoUndo(t *testing.T) {
+	tree := openTestDiskTree(t, filepath.Join(t.TempDir(), "tree.db"), WithOrder(8))
+	defer tree.Close()
+	for key := 0; key < 500; key++ {
+		_ = tree.Insert(key, fmt.Sprint(key))
+	}
+	for key := 0; key < 500; key += 7 {
+		if _, ok, err := tree.Get(key); err != nil || !ok {
+			t.Fatalf("get %d: %v %v", key, ok, err)
+		}
+	}
+	if _, _, err := tree.FindRange(100, 400); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(tree.pool.saved) != 0 {
+		t.Errorf("reads saved undo for %d pages", len(tree.pool.saved))
+	}
+	for _, f := range tree.pool.frames {
+		if f.undo != nil {
+			t.Fatalf("page %d has undo after reads", f.id)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package bptree
+
+import (
+	"errors"
+	"fmt"
+)
+
+// *********************** 错误 ***********************
+/*
+所有操作返回的错误都可以用errors.Is判断类型:
+1. 与某个key有关的错误(ErrKeyExists/ErrKeyNotFound)是*KeyError,用errors.As可以取出key。
+2. 文件内容损坏(校验和错误、页号越界等)以及Validate发现的结构问题包装ErrCorrupt,数据超过页大小包装ErrTooLarge。
+3. 参数无效包装ErrInvalidOrder(阶数)或者ErrInvalidArgument(页大小、fillFactor等),
+   BulkLoad的树不为空时返回ErrNotEmpty,数据不是严格递增时包装ErrUnsorted。
+   内存中的树没有返回错误的构造函数,NewTree/NewTreeFunc的配置无效时以同样的error panic。
+4. buffer pool的page都被pin住时返回ErrPoolFull,释放还被pin住的page时包装ErrPagePinned。
+查找时key不存在是常见情况,不需要错误信息时用Get,不会创建错误。
+*/
+
+var (
+	// ErrKeyExists :Insert的key已经存在
+	ErrKeyExists = errors.New("bptree: key already exists")
+	// ErrKeyNotFound :Find/Delete/Update的key不存在
+	ErrKeyNotFound = errors.New("bptree: key not found")
+	// ErrCorrupt :文件内容或者树结构损坏
+	ErrCorrupt = errors.New("bptree: corrupt data")
+	// ErrTooLarge :key/value或者节点超过页大小
+	ErrTooLarge = errors.New("bptree: too large for page size")
+	// ErrInvalidOrder :阶数小于3 或者阶数太大 满节点放不进一个page
+	ErrInvalidOrder = errors.New("bptree: invalid order")
+	// ErrInvalidArgument :参数超出取值范围
+	ErrInvalidArgument = errors.New("bptree: invalid argument")
+	// ErrNotEmpty :BulkLoad的树不为空
+	ErrNotEmpty = errors.New("bptree: tree is not empty")
+	// ErrUnsorted :BulkLoad的数据不是按key严格递增
+	ErrUnsorted = errors.New("bptree: keys are not strictly increasing")
+	// ErrPoolFull :所有frame都被pin住 没有可以淘汰的page
+	ErrPoolFull = errors.New("bptree: all pages in buffer pool are pinned")
+	// ErrPagePinned :释放的page还被pin住
+	ErrPagePinned = errors.New("bptree: page is pinned")
+)
+
+// KeyError :带有key的错误 Err是ErrKeyExists或者ErrKeyNotFound
+type KeyError[K any] struct {
+	Key K
+	Err error
+}
+
+func (e *KeyError[K]) Error() string {
+	return fmt.Sprintf("%s: %v", e.Err, e.Key)
+}
+
+func (e *KeyError[K]) Unwrap() error {
+	return e.Err
+}
+
+func keyError[K any](err error, key K) error {
+	return &KeyError[K]{Key: key, Err: err}
+}
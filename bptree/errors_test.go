@@ -0,0 +1,122 @@
+package bptree
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	for _, opts := range [][]Option{{WithOrder(4)}, {WithOrder(4), WithConcurrency()}} {
+		tree := NewTree[int, string](opts...)
+		for key := 0; key < 50; key++ {
+			_ = tree.Insert(key, "v")
+		}
+
+		err := tree.Insert(7, "v")
+		var keyErr *KeyError[int]
+		if !errors.Is(err, ErrKeyExists) || !errors.As(err, &keyErr) || keyErr.Key != 7 {
+			t.Errorf("expected ErrKeyExists for key 7 and got %v", err)
+		}
+		if err.Error() != "bptree: key already exists: 7" {
+			t.Errorf("unexpected message %q", err)
+		}
+		for _, err := range []error{
+			tree.Delete(100),
+			tree.Update(100, func(old string) (string, bool) { return old, true }),
+			func() error { _, err := tree.Find(100); return err }(),
+			func() error { _, err := tree.Snapshot().Find(100); return err }(),
+			func() error { _, err := tree.Begin().Find(100); return err }(),
+			tree.Begin().Delete(100),
+		} {
+			if !errors.Is(err, ErrKeyNotFound) || !errors.As(err, &keyErr) || keyErr.Key != 100 {
+				t.Errorf("expected ErrKeyNotFound for key 100 and got %v", err)
+			}
+		}
+		if err := tree.Begin().Insert(7, "v"); !errors.Is(err, ErrKeyExists) {
+			t.Errorf("expected ErrKeyExists and got %v", err)
+		}
+
+		// Get
+		if v, ok := tree.Get(7); !ok || v != "v" {
+			t.Errorf("expected v and got %q %v", v, ok)
+		}
+		if v, ok := tree.Get(100); ok || v != "" {
+			t.Errorf("expected missing key and got %q", v)
+		}
+		if v, ok := tree.Snapshot().Get(8); !ok || v != "v" {
+			t.Errorf("expected v in snapshot and got %q %v", v, ok)
+		}
+	}
+}
+
+// Get找不到key时不创建错误
+func TestGetMissNoAlloc(t *testing.T) {
+	tree := NewTree[int, int]()
+	for key := 0; key < 100; key += 2 {
+		_ = tree.Insert(key, key)
+	}
+	if n := testing.AllocsPerRun(100, func() { _, _ = tree.Get(51) }); n != 0 {
+		t.Errorf("expected no allocations and got %v", n)
+	}
+}
+
+func TestDiskTreeErrors(t *testing.T) {
+	tree := openTestDiskTree(t, filepath.Join(t.TempDir(), "tree.db"))
+	defer tree.Close()
+	_ = tree.Insert(1, "a")
+
+	if err := tree.Insert(1, "b"); !errors.Is(err, ErrKeyExists) {
+		t.Errorf("expected ErrKeyExists and got %v", err)
+	}
+	if err := tree.Delete(2); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound and got %v", err)
+	}
+	if _, err := tree.Find(2); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound and got %v", err)
+	}
+	if v, ok, err := tree.Get(1); err != nil || !ok || v != "a" {
+		t.Errorf("expected a and got %q %v %v", v, ok, err)
+	}
+	if _, ok, err := tree.Get(2); err != nil || ok {
+		t.Errorf("expected missing key and got %v %v", ok, err)
+	}
+}
+
+func TestArgumentErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := OpenPager(filepath.Join(dir, "small.db"), minPageSize-1); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument and got %v", err)
+	}
+	pager, err := OpenPager(filepath.Join(dir, "pager.db"), DefaultPageSize)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer pager.Close()
+	if err := pager.WritePage(PageID(pager.meta.pageCount), make([]byte, DefaultPageSize)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected ErrCorrupt and got %v", err)
+	}
+
+	tree := NewTree[int, int]()
+	if err := tree.BulkLoad(sortedSeq(10), 1.5); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument and got %v", err)
+	}
+	err = tree.BulkLoad(func(yield func(int, int) bool) {
+		for _, key := range []int{1, 3, 2} {
+			if !yield(key, key) {
+				return
+			}
+		}
+	}, 1)
+	if !errors.Is(err, ErrUnsorted) || err.Error() != "bptree: keys are not strictly increasing: 2 after 3" {
+		t.Errorf("expected ErrUnsorted and got %v", err)
+	}
+	_ = tree.Insert(1, 1)
+	if err := tree.BulkLoad(sortedSeq(10), 1); !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("expected ErrNotEmpty and got %v", err)
+	}
+
+	if _, err := Open[int, string](filepath.Join(dir, "tree.db"), IntCodec{}, StringCodec{}, WithOrder(2)); !errors.Is(err, ErrInvalidOrder) {
+		t.Errorf("expected ErrInvalidOrder and got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package bptree
+
+import "iter"
+
+// *********************** range-over-func迭代器 ***********************
+/*
+All/Backward/Range返回iter.Seq2 可以直接用于for range:
+	for k, v := range tree.Range(a, b) { ... }
+迭代器基于Cursor实现,不持有latch也不复制数据,提前break不会泄漏任何资源。
+并发模式下迭代过程中其他goroutine的修改可能被看到,但key总是严格按顺序返回。
+*/
+
+// All :按key从小到大遍历
+func (t *Tree[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		c := t.Cursor()
+		for ok := c.SeekFirst(); ok; ok = c.Next() {
+			if !yield(c.Key(), c.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// Backward :按key从大到小遍历
+func (t *Tree[K, V]) Backward() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		c := t.Cursor()
+		for ok := c.SeekLast(); ok; ok = c.Prev() {
+			if !yield(c.Key(), c.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// Range :按key从小到大遍历lo<=key<hi的数据
+func (t *Tree[K, V]) Range(lo, hi K) iter.Seq2[K, V] {
+	return t.Scan(RangeQuery[K]{Lower: Included(lo), Upper: Excluded(hi)})
+}
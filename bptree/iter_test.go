@@ -0,0 +1,64 @@
+package bptree
+
+import (
+	"reflect"
+	"testing"
+)
+
+func collect[K any, V any](seq func(yield func(K, V) bool)) ([]K, []V) {
+	keys, values := make([]K, 0), make([]V, 0)
+	for k, v := range seq {
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+	return keys, values
+}
+
+func TestIterators(t *testing.T) {
+	for _, opts := range [][]Option{{WithOrder(5)}, {WithOrder(5), WithConcurrency()}} {
+		tree := NewTree[int, string](opts...)
+		if keys, _ := collect(tree.All()); len(keys) != 0 {
+			t.Errorf("expected no keys in empty tree")
+		}
+
+		for key := 0; key < 300; key++ {
+			_ = tree.Insert(key, string(rune('a'+key%26)))
+		}
+
+		keys, values := collect(tree.All())
+		if len(keys) != 300 || keys[0] != 0 || keys[299] != 299 || values[27] != "b" {
+			t.Errorf("unexpected All result, %d keys", len(keys))
+		}
+
+		back, _ := collect(tree.Backward())
+		for i := range back {
+			if back[i] != 299-i {
+				t.Fatalf("unexpected Backward result at %d: %d", i, back[i])
+			}
+		}
+
+		// 半开区间[lo, hi)
+		keys, _ = collect(tree.Range(100, 105))
+		if !reflect.DeepEqual(keys, []int{100, 101, 102, 103, 104}) {
+			t.Errorf("unexpected Range result %v", keys)
+		}
+		if keys, _ = collect(tree.Range(105, 100)); len(keys) != 0 {
+			t.Errorf("expected empty range and got %v", keys)
+		}
+		if keys, _ = collect(tree.Range(290, 1000)); len(keys) != 10 {
+			t.Errorf("expected 10 keys and got %d", len(keys))
+		}
+
+		// 提前break
+		count := 0
+		for k := range tree.All() {
+			if k == 9 {
+				break
+			}
+			count++
+		}
+		if count != 9 {
+			t.Errorf("expected to stop after 9 keys and got %d", count)
+		}
+	}
+}
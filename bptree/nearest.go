@@ -0,0 +1,74 @@
+package bptree
+
+// *********************** 近邻查询 ***********************
+/*
+Floor/Ceiling/Lower/Higher查找与key最接近的数据,Min/Max查找最小和最大的key,都返回key、Record和是否找到:
+从key所在的叶子节点开始,沿叶子链表向右(Ceiling/Higher)或者向左(Floor/Lower)找到第一个满足条件的key。
+例如按版本号保存的配置,Floor(v)就是不超过v的最新版本。
+*/
+
+// Floor :小于等于key的最大key
+func (t *Tree[K, V]) Floor(key K) (K, *Record[V], bool) {
+	return t.seek(key, false, func(k K) bool { return t.compare(k, key) <= 0 })
+}
+
+// Ceiling :大于等于key的最小key
+func (t *Tree[K, V]) Ceiling(key K) (K, *Record[V], bool) {
+	return t.seek(key, true, func(k K) bool { return t.compare(k, key) >= 0 })
+}
+
+// Lower :小于key的最大key(前驱)
+func (t *Tree[K, V]) Lower(key K) (K, *Record[V], bool) {
+	return t.seek(key, false, func(k K) bool { return t.compare(k, key) < 0 })
+}
+
+// Higher :大于key的最小key(后继)
+func (t *Tree[K, V]) Higher(key K) (K, *Record[V], bool) {
+	return t.seek(key, true, func(k K) bool { return t.compare(k, key) > 0 })
+}
+
+// Min :最小的key
+func (t *Tree[K, V]) Min() (K, *Record[V], bool) {
+	if t.concurrent {
+		return t.latchedSeekEdge(true)
+	}
+	return scanLeaves(t.edgeLeaf(false), true, func(K) bool { return true })
+}
+
+// Max :最大的key
+func (t *Tree[K, V]) Max() (K, *Record[V], bool) {
+	if t.concurrent {
+		return t.latchedSeekEdge(false)
+	}
+	return scanLeaves(t.edgeLeaf(true), false, func(K) bool { return true })
+}
+
+// 从key所在的叶子节点开始 forward时向右找第一个满足accept的key 否则向左找最后一个
+func (t *Tree[K, V]) seek(key K, forward bool, accept func(k K) bool) (K, *Record[V], bool) {
+	if t.concurrent {
+		return t.latchedSeek(key, forward, accept)
+	}
+	return scanLeaves(t.findLeaf(key), forward, accept)
+}
+
+// 非并发模式下沿叶子链表查找 与scanLatched相同但不加latch
+func scanLeaves[K any, V any](n *Node[K, V], forward bool, accept func(k K) bool) (key K, r *Record[V], found bool) {
+	for n != nil {
+		if forward {
+			for i := 0; i < n.Count; i++ {
+				if accept(n.Keys[i]) {
+					return n.Keys[i], n.Pointers[i].(*Record[V]), true
+				}
+			}
+			n = n.Next
+		} else {
+			for i := n.Count - 1; i >= 0; i-- {
+				if accept(n.Keys[i]) {
+					return n.Keys[i], n.Pointers[i].(*Record[V]), true
+				}
+			}
+			n = n.Prev
+		}
+	}
+	return key, nil, false
+}
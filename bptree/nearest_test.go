@@ -0,0 +1,60 @@
+package bptree
+
+import (
+	"sort"
+	"testing"
+)
+
+// 与排好序的切片对比Floor/Ceiling/Lower/Higher/Min/Max
+func TestNearest(t *testing.T) {
+	for _, opts := range [][]Option{{WithOrder(4)}, {WithOrder(4), WithConcurrency()}} {
+		tree := NewTree[int, int](opts...)
+		if _, _, ok := tree.Min(); ok {
+			t.Errorf("expected no min key in empty tree")
+		}
+		if _, _, ok := tree.Floor(1); ok {
+			t.Errorf("expected no floor key in empty tree")
+		}
+
+		r := testRand(t)
+		sorted := make([]int, 0)
+		for len(sorted) < 300 {
+			key := r.Intn(1000) * 2
+			if tree.Insert(key, key) == nil {
+				sorted = append(sorted, key)
+			}
+		}
+		sort.Ints(sorted)
+
+		check := func(name string, key int, pos int, got int, rec *Record[int], ok bool) {
+			t.Helper()
+			if pos < 0 || pos >= len(sorted) {
+				if ok {
+					t.Fatalf("%s(%d): expected no key and got %d", name, key, got)
+				}
+				return
+			}
+			if !ok || got != sorted[pos] || rec.Value != sorted[pos] {
+				t.Fatalf("%s(%d): expected %d and got %d %v", name, key, sorted[pos], got, ok)
+			}
+		}
+		// i是第一个>=key的位置 j是第一个>key的位置
+		for key := -3; key < 2003; key++ {
+			i := sort.SearchInts(sorted, key)
+			j := sort.SearchInts(sorted, key+1)
+			k, rec, ok := tree.Floor(key)
+			check("Floor", key, j-1, k, rec, ok)
+			k, rec, ok = tree.Ceiling(key)
+			check("Ceiling", key, i, k, rec, ok)
+			k, rec, ok = tree.Lower(key)
+			check("Lower", key, i-1, k, rec, ok)
+			k, rec, ok = tree.Higher(key)
+			check("Higher", key, j, k, rec, ok)
+		}
+
+		k, rec, ok := tree.Min()
+		check("Min", 0, 0, k, rec, ok)
+		k, rec, ok = tree.Max()
+		check("Max", 0, len(sorted)-1, k, rec, ok)
+	}
+}
@@ -0,0 +1,128 @@
+package bptree
+
+import (
+	"fmt"
+	"math"
+)
+
+// Option :Tree的可选配置 在NewTree/NewTreeFunc时传入
+type Option func(*config)
+
+type config struct {
+	order      int     // 树的阶数
+	fillFactor float64 // 节点分裂时左节点保留的key比例
+	concurrent bool    // 是否开启并发模式
+	pageSize   int     // DiskTree的page大小
+	poolSize   int     // DiskTree缓存的page数量
+}
+
+func defaultConfig() config {
+	return config{
+		order:      ORDER,
+		fillFactor: 0.5,
+		pageSize:   DefaultPageSize,
+		poolSize:   DefaultPoolSize,
+	}
+}
+
+// WithOrder :设置树的阶数(每个节点最多order-1个key) 阶数至少为3
+/*
+阶数无效时NewTree/NewTreeFunc panic(与make传入负数长度相同,属于调用者的编程错误),
+Open/OpenFunc返回ErrInvalidOrder。
+*/
+func WithOrder(order int) Option {
+	return func(c *config) {
+		c.order = order
+	}
+}
+
+// WithFillFactor :设置节点分裂时左节点的填充比例 取值(0,1]
+/*
+默认0.5即从中间分裂;顺序递增插入的场景可以调大(比如0.9),让分裂后的左节点尽量满。
+实际的分裂点会被限制在保证左右两个节点都不少于最小key数量的范围内。
+取值无效时NewTree/NewTreeFunc panic,Open/OpenFunc返回ErrInvalidArgument。
+*/
+func WithFillFactor(f float64) Option {
+	return func(c *config) {
+		c.fillFactor = f
+	}
+}
+
+// WithConcurrency :开启并发模式
+/*
+开启后Insert/Delete/Find/FindRange可以被多个goroutine同时调用,
+内部使用节点级别的latch crabbing,而不是一把全局锁。
+不开启时没有任何加锁开销,tree只能在单个goroutine中使用。
+*/
+func WithConcurrency() Option {
+	return func(c *config) {
+		c.concurrent = true
+	}
+}
+
+// WithPageSize :设置DiskTree的page大小(字节) 只在创建新文件时生效
+func WithPageSize(size int) Option {
+	return func(c *config) {
+		c.pageSize = size
+	}
+}
+
+// WithPoolSize :设置DiskTree的buffer pool最多缓存的page数量 内存占用约为pages*pageSize
+func WithPoolSize(pages int) Option {
+	return func(c *config) {
+		c.poolSize = pages
+	}
+}
+
+// 检查阶数和填充比例 错误包装ErrInvalidOrder或者ErrInvalidArgument
+func (c config) validate() error {
+	if c.order < 3 {
+		return fmt.Errorf("%w %d: must be at least 3", ErrInvalidOrder, c.order)
+	}
+	if c.fillFactor <= 0 || c.fillFactor > 1 {
+		return fmt.Errorf("%w: fill factor %v must be in (0, 1]", ErrInvalidArgument, c.fillFactor)
+	}
+	return nil
+}
+
+// 根据配置计算tree的节点容量和分裂点 配置无效时panic
+func (c config) apply(t *treeLimits) {
+	if err := c.validate(); err != nil {
+		panic(err)
+	}
+
+	t.order = c.order
+	t.maxLimit = c.order - 1
+	t.minLimit = (c.order - 1) / 2
+
+	// 分裂点 = ceil(fillFactor * maxLimit) fillFactor为0.5时与中间分裂一致
+	split := int(math.Ceil(c.fillFactor * float64(t.maxLimit)))
+	// 叶子节点: 临时插入后共maxLimit+1个key 左split个 右maxLimit+1-split个
+	t.leafSplit = clamp(split, t.minLimit, t.maxLimit+1-t.minLimit)
+	// 非叶子节点: 左split个 中间1个上移到parent 右maxLimit-split个
+	t.nodeSplit = clamp(split, t.minLimit, t.maxLimit-t.minLimit)
+}
+
+// treeLimits :每棵树独立的容量参数
+type treeLimits struct {
+	order     int // 树的阶数
+	maxLimit  int // 节点的key数量上限
+	minLimit  int // 节点的key数量下限(根节点除外)
+	leafSplit int // 叶子节点分裂的索引值
+	nodeSplit int // 非叶子节点分裂的索引值
+}
+
+// Order :返回树的阶数
+func (l *treeLimits) Order() int {
+	return l.order
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
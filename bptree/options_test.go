@@ -0,0 +1,125 @@
+package bptree
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultOrder(t *testing.T) {
+	tree := NewTree[int, int]()
+	if tree.Order() != ORDER || tree.maxLimit != MAX_LIMIT || tree.minLimit != MIN_LIMIT {
+		t.Errorf("expected order %d and got %d", ORDER, tree.Order())
+	}
+	// 默认从中间分裂 与原先的splitIndex一致
+	if tree.leafSplit != 2 || tree.nodeSplit != 2 {
+		t.Errorf("expected split index 2 and got %d/%d", tree.leafSplit, tree.nodeSplit)
+	}
+}
+
+func TestWithOrder(t *testing.T) {
+	for _, order := range []int{3, 4, 5, 8, 33, 128} {
+		tree := NewTree[int, int](WithOrder(order))
+		num := order * order * 3
+		r := testRand(t)
+		for _, key := range r.Perm(num) {
+			if err := tree.Insert(key, key); err != nil {
+				t.Fatalf("order %d: %s", order, err)
+			}
+		}
+
+		checkOccupancy(t, tree)
+		for key := 0; key < num; key++ {
+			if rec, err := tree.Find(key); err != nil || rec.Value != key {
+				t.Fatalf("order %d: find %d failed: %v", order, key, err)
+			}
+		}
+	}
+}
+
+func TestWithFillFactor(t *testing.T) {
+	tree := NewTree[int, int](WithOrder(10), WithFillFactor(1))
+	half := NewTree[int, int](WithOrder(10))
+
+	// 顺序插入时 左节点填满 叶子节点数量明显更少
+	for key := 0; key < 1000; key++ {
+		_ = tree.Insert(key, key)
+		_ = half.Insert(key, key)
+	}
+	checkOccupancy(t, tree)
+	checkOccupancy(t, half)
+
+	if a, b := countLeaves(tree), countLeaves(half); a >= b {
+		t.Errorf("expected fewer leaves with fill factor 1, got %d and %d", a, b)
+	}
+}
+
+// 不同阶数的树互不影响
+func TestIndependentOrders(t *testing.T) {
+	small := NewTree[int, int](WithOrder(3))
+	wide := NewTree[int, int](WithOrder(128))
+
+	for key := 0; key < 500; key++ {
+		_ = small.Insert(key, key)
+		_ = wide.Insert(key, key)
+	}
+
+	if small.height() <= wide.height() {
+		t.Errorf("expected small order tree to be higher, got %d and %d", small.height(), wide.height())
+	}
+	if wide.height() != 2 {
+		t.Errorf("expected height 2 and got %d", wide.height())
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for _, c := range []struct {
+		opt  Option
+		want error
+	}{{WithOrder(2), ErrInvalidOrder}, {WithFillFactor(0), ErrInvalidArgument}, {WithFillFactor(1.5), ErrInvalidArgument}} {
+		func() {
+			defer func() {
+				if err, _ := recover().(error); !errors.Is(err, c.want) {
+					t.Errorf("expected panic with %v and got %v", c.want, err)
+				}
+			}()
+			NewTree[int, int](c.opt)
+		}()
+
+		_, err := Open[int, string](filepath.Join(t.TempDir(), "tree.db"), IntCodec{}, StringCodec{}, c.opt)
+		if !errors.Is(err, c.want) {
+			t.Errorf("expected %v from Open and got %v", c.want, err)
+		}
+	}
+}
+
+// 检查非根节点的key数量都在[minLimit, maxLimit]之间
+func checkOccupancy[K any, V any](t *testing.T, tree *Tree[K, V]) {
+	t.Helper()
+	var walk func(n *Node[K, V])
+	walk = func(n *Node[K, V]) {
+		if n != tree.Root && (n.Count < tree.minLimit || n.Count > tree.maxLimit) {
+			t.Fatalf("order %d: node has %d keys", tree.order, n.Count)
+		}
+		if n.IsLeaf {
+			return
+		}
+		for _, p := range n.Pointers {
+			walk(p.(*Node[K, V]))
+		}
+	}
+	if tree.Root != nil {
+		walk(tree.Root)
+	}
+}
+
+func countLeaves[K any, V any](tree *Tree[K, V]) (count int) {
+	n := tree.Root
+	for !n.IsLeaf {
+		n = n.Pointers[0].(*Node[K, V])
+	}
+	for ; n != nil; n = n.Next {
+		count++
+	}
+	return count
+}
@@ -0,0 +1,166 @@
+package bptree
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// *********************** 节点page编解码 ***********************
+/*
+节点page的布局:
+[0:4]crc32 [4]类型 [5:7]key数量 [7:15]Next [15:23]Prev [23:]数据
+叶子节点的数据: 依次是 uvarint(len(key)) key uvarint(len(value)) value
+非叶子节点的数据: 先是count+1个8字节的子节点PageID 再依次是 uvarint(len(key)) key
+空闲page只使用[7:15]保存空闲链表的下一个page。
+*/
+
+const (
+	pageLeaf     = 1 // 叶子节点
+	pageInternal = 2 // 非叶子节点
+	pageFree     = 3 // 空闲page
+
+	pageHeaderSize = 23
+)
+
+// diskNode :page解码之后的节点 对应内存树的Node,指针换成了PageID
+type diskNode[K any, V any] struct {
+	id       PageID
+	isLeaf   bool
+	keys     []K
+	values   []V      // 叶子节点的数据
+	children []PageID // 非叶子节点的子节点 len(children) == len(keys)+1
+	next     PageID   // 叶子节点双向链表 下一节点
+	prev     PageID   // 叶子节点双向链表 上一节点
+}
+
+// 二分查找key 返回第一个>=key的位置 以及key是否存在
+func (n *diskNode[K, V]) search(key K, compare func(a, b K) int) (int, bool) {
+	return slices.BinarySearchFunc(n.keys, key, compare)
+}
+
+// 非叶子节点中 key所在子树的index(第一个大于key的位置)
+func (n *diskNode[K, V]) childIndex(key K, compare func(a, b K) int) int {
+	i, found := n.search(key, compare)
+	if found {
+		i++
+	}
+	return i
+}
+
+// 把节点编码到page缓冲区 超过page大小返回错误
+func (t *DiskTree[K, V]) encodeNode(n *diskNode[K, V], page []byte) error {
+	data := t.scratch[:0]
+	if n.isLeaf {
+		for i, key := range n.keys {
+			t.field = t.keys.Append(t.field[:0], key)
+			data = appendField(data, t.field)
+			t.field = t.values.Append(t.field[:0], n.values[i])
+			data = appendField(data, t.field)
+		}
+	} else {
+		for _, child := range n.children {
+			data = binary.BigEndian.AppendUint64(data, uint64(child))
+		}
+		for _, key := range n.keys {
+			t.field = t.keys.Append(t.field[:0], key)
+			data = appendField(data, t.field)
+		}
+	}
+	t.scratch = data
+
+	if pageHeaderSize+len(data) > len(page) {
+		return fmt.Errorf("%w: node %d overflows page size %d", ErrTooLarge, n.id, len(page))
+	}
+
+	clear(page)
+	page[4] = pageInternal
+	if n.isLeaf {
+		page[4] = pageLeaf
+	}
+	binary.BigEndian.PutUint16(page[5:7], uint16(len(n.keys)))
+	binary.BigEndian.PutUint64(page[7:15], uint64(n.next))
+	binary.BigEndian.PutUint64(page[15:23], uint64(n.prev))
+	copy(page[pageHeaderSize:], data)
+	return nil
+}
+
+// 追加一个带长度前缀的字段
+func appendField(data []byte, field []byte) []byte {
+	data = binary.AppendUvarint(data, uint64(len(field)))
+	return append(data, field...)
+}
+
+// 从page缓冲区解码节点 crc32已经在BufferPool从文件读入时校验过
+func (t *DiskTree[K, V]) decodeNode(id PageID, page []byte) (*diskNode[K, V], error) {
+	corrupt := func() (*diskNode[K, V], error) {
+		return nil, fmt.Errorf("%w: page %d", ErrCorrupt, id)
+	}
+	if page[4] != pageLeaf && page[4] != pageInternal {
+		return corrupt()
+	}
+
+	count := int(binary.BigEndian.Uint16(page[5:7]))
+	n := &diskNode[K, V]{
+		id:     id,
+		isLeaf: page[4] == pageLeaf,
+		keys:   make([]K, 0, count),
+		next:   PageID(binary.BigEndian.Uint64(page[7:15])),
+		prev:   PageID(binary.BigEndian.Uint64(page[15:23])),
+	}
+	data := page[pageHeaderSize:]
+
+	// 读取一个带长度前缀的字段
+	field := func() ([]byte, bool) {
+		size, l := binary.Uvarint(data)
+		if l <= 0 || uint64(len(data)-l) < size {
+			return nil, false
+		}
+		f := data[l : l+int(size)]
+		data = data[l+int(size):]
+		return f, true
+	}
+
+	if n.isLeaf {
+		n.values = make([]V, 0, count)
+	} else {
+		if len(data) < 8*(count+1) {
+			return corrupt()
+		}
+		n.children = make([]PageID, count+1)
+		for i := range n.children {
+			n.children[i] = PageID(binary.BigEndian.Uint64(data[8*i:]))
+		}
+		data = data[8*(count+1):]
+	}
+
+	for i := 0; i < count; i++ {
+		f, ok := field()
+		if !ok {
+			return corrupt()
+		}
+		key, err := t.keys.Decode(f)
+		if err != nil {
+			return corrupt()
+		}
+		n.keys = append(n.keys, key)
+
+		if n.isLeaf {
+			if f, ok = field(); !ok {
+				return corrupt()
+			}
+			value, err := t.values.Decode(f)
+			if err != nil {
+				return corrupt()
+			}
+			n.values = append(n.values, value)
+		}
+	}
+	return n, nil
+}
+
+// uvarint编码后的长度
+func uvarintLen(x int) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(buf[:], uint64(x))
+}
@@ -0,0 +1,217 @@
+package bptree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// *********************** Pager ***********************
+/*
+磁盘文件按固定大小的page划分:page 0是meta页,其余每个page存放一个节点或者是空闲page。
+节点之间用PageID相互引用(代替内存中的指针),释放的page串成空闲链表重复利用。
+每个page的前4个字节是其余部分的crc32,读取时用来发现损坏的数据。
+*/
+
+// PageID :page在文件中的编号 偏移量 = PageID * pageSize
+type PageID uint64
+
+const (
+	DefaultPageSize = 4096 // 默认page大小
+	minPageSize     = 512  // page大小下限
+
+	nilPage PageID = 0 // 空引用(0号page是meta页 不会被节点引用)
+
+	metaMagic = "ZBPTREE1"
+)
+
+// meta :0号page中保存的元数据
+/*
+[0:4]crc32 [4:12]magic [12:16]pageSize [16:20]order [20:28]root [28:36]pageCount [36:44]freeHead
+*/
+type meta struct {
+	order     int    // 树的阶数(创建时确定)
+	root      PageID // 根节点
+	pageCount uint64 // 文件中的page数量(包括meta页)
+	freeHead  PageID // 空闲page链表头
+}
+
+// Pager :以page为单位读写单个文件
+type Pager struct {
+	file     *os.File
+	pageSize int
+	meta     meta
+}
+
+// OpenPager :打开或创建page文件 pageSize只在创建新文件时生效,已有文件使用创建时的pageSize
+func OpenPager(path string, pageSize int) (*Pager, error) {
+	if pageSize < minPageSize {
+		return nil, fmt.Errorf("%w: page size %d is smaller than %d", ErrInvalidArgument, pageSize, minPageSize)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pager{file: file, pageSize: pageSize}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if info.Size() == 0 {
+		// 新文件 只有meta页
+		p.meta.pageCount = 1
+		err = p.writeMeta()
+	} else {
+		err = p.readMeta()
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// PageSize :page大小
+func (p *Pager) PageSize() int {
+	return p.pageSize
+}
+
+// PageCount :文件中的page数量(包括meta页和空闲page)
+func (p *Pager) PageCount() int {
+	return int(p.meta.pageCount)
+}
+
+// ReadPage :读取page到buf中 len(buf)必须等于pageSize
+func (p *Pager) ReadPage(id PageID, buf []byte) error {
+	if id == nilPage || uint64(id) >= p.meta.pageCount {
+		return fmt.Errorf("%w: page %d out of range", ErrCorrupt, id)
+	}
+	if _, err := p.file.ReadAt(buf[:p.pageSize], int64(id)*int64(p.pageSize)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// WritePage :把buf写入page 写入前自动填充校验和
+func (p *Pager) WritePage(id PageID, buf []byte) error {
+	if id == nilPage || uint64(id) >= p.meta.pageCount {
+		return fmt.Errorf("%w: page %d out of range", ErrCorrupt, id)
+	}
+	setChecksum(buf[:p.pageSize])
+	_, err := p.file.WriteAt(buf[:p.pageSize], int64(id)*int64(p.pageSize))
+	return err
+}
+
+// Allocate :分配一个page 优先复用空闲链表 内容由调用方写入
+func (p *Pager) Allocate() (PageID, error) {
+	if p.meta.freeHead == nilPage {
+		return p.grow(), nil
+	}
+
+	id := p.meta.freeHead
+	buf := make([]byte, p.pageSize)
+	if err := p.ReadPage(id, buf); err != nil {
+		return nilPage, err
+	}
+	if !checksumOK(buf) || buf[4] != pageFree {
+		return nilPage, fmt.Errorf("%w: free page %d", ErrCorrupt, id)
+	}
+	p.meta.freeHead = PageID(binary.BigEndian.Uint64(buf[7:15]))
+	return id, nil
+}
+
+// 在文件末尾增加一个page
+func (p *Pager) grow() PageID {
+	id := PageID(p.meta.pageCount)
+	p.meta.pageCount++
+	return id
+}
+
+// Free :释放page 加入空闲链表
+func (p *Pager) Free(id PageID) error {
+	buf := make([]byte, p.pageSize)
+	buf[4] = pageFree
+	binary.BigEndian.PutUint64(buf[7:15], uint64(p.meta.freeHead))
+	if err := p.WritePage(id, buf); err != nil {
+		return err
+	}
+	p.meta.freeHead = id
+	return nil
+}
+
+// Sync :写入meta页并刷盘
+func (p *Pager) Sync() error {
+	if err := p.writeMeta(); err != nil {
+		return err
+	}
+	return p.file.Sync()
+}
+
+// Close :刷盘并关闭文件
+func (p *Pager) Close() error {
+	if err := p.Sync(); err != nil {
+		p.file.Close()
+		return err
+	}
+	return p.file.Close()
+}
+
+func (p *Pager) writeMeta() error {
+	buf := make([]byte, p.pageSize)
+	copy(buf[4:12], metaMagic)
+	binary.BigEndian.PutUint32(buf[12:16], uint32(p.pageSize))
+	binary.BigEndian.PutUint32(buf[16:20], uint32(p.meta.order))
+	binary.BigEndian.PutUint64(buf[20:28], uint64(p.meta.root))
+	binary.BigEndian.PutUint64(buf[28:36], p.meta.pageCount)
+	binary.BigEndian.PutUint64(buf[36:44], uint64(p.meta.freeHead))
+	setChecksum(buf)
+	_, err := p.file.WriteAt(buf, 0)
+	return err
+}
+
+func (p *Pager) readMeta() error {
+	// 先读固定的头部拿到pageSize 再读整个meta页校验
+	head := make([]byte, 44)
+	if _, err := p.file.ReadAt(head, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w: meta page", ErrCorrupt)
+		}
+		return err
+	}
+	if string(head[4:12]) != metaMagic {
+		return fmt.Errorf("%w: not a bptree file", ErrCorrupt)
+	}
+
+	p.pageSize = int(binary.BigEndian.Uint32(head[12:16]))
+	if p.pageSize < minPageSize {
+		return fmt.Errorf("%w: meta page", ErrCorrupt)
+	}
+	buf := make([]byte, p.pageSize)
+	if _, err := p.file.ReadAt(buf, 0); err != nil {
+		return err
+	}
+	if !checksumOK(buf) {
+		return fmt.Errorf("%w: meta page", ErrCorrupt)
+	}
+
+	p.meta.order = int(binary.BigEndian.Uint32(buf[16:20]))
+	p.meta.root = PageID(binary.BigEndian.Uint64(buf[20:28]))
+	p.meta.pageCount = binary.BigEndian.Uint64(buf[28:36])
+	p.meta.freeHead = PageID(binary.BigEndian.Uint64(buf[36:44]))
+	return nil
+}
+
+func setChecksum(page []byte) {
+	binary.BigEndian.PutUint32(page[0:4], crc32.ChecksumIEEE(page[4:]))
+}
+
+func checksumOK(page []byte) bool {
+	return binary.BigEndian.Uint32(page[0:4]) == crc32.ChecksumIEEE(page[4:])
+}
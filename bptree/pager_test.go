@@ -0,0 +1,95 @@
+package bptree
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPagerReadWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pages.db")
+	p, err := OpenPager(path, 1024)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	ids := make([]PageID, 0)
+	for i := 0; i < 5; i++ {
+		id, err := p.Allocate()
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		buf := make([]byte, p.PageSize())
+		buf[10] = byte(i)
+		if err := p.WritePage(id, buf); err != nil {
+			t.Fatalf("%s", err)
+		}
+		ids = append(ids, id)
+	}
+	if ids[0] != 1 || p.PageCount() != 6 {
+		t.Errorf("expected first page 1 and 6 pages, got %d and %d", ids[0], p.PageCount())
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// 重新打开 使用文件中的pageSize
+	p, err = OpenPager(path, DefaultPageSize)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer p.Close()
+	if p.PageSize() != 1024 || p.PageCount() != 6 {
+		t.Errorf("expected page size 1024 and 6 pages, got %d and %d", p.PageSize(), p.PageCount())
+	}
+
+	buf := make([]byte, p.PageSize())
+	if err := p.ReadPage(ids[3], buf); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if buf[10] != 3 || !checksumOK(buf) {
+		t.Errorf("unexpected page content")
+	}
+	if err := p.ReadPage(PageID(100), buf); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+	if err := p.ReadPage(nilPage, buf); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+}
+
+// 释放的page按后进先出的顺序复用
+func TestPagerFreeList(t *testing.T) {
+	p, err := OpenPager(filepath.Join(t.TempDir(), "pages.db"), minPageSize)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer p.Close()
+
+	buf := make([]byte, p.PageSize())
+	for i := 0; i < 4; i++ {
+		id, _ := p.Allocate()
+		_ = p.WritePage(id, buf)
+	}
+	_ = p.Free(2)
+	_ = p.Free(4)
+
+	for _, want := range []PageID{4, 2, 5} {
+		if id, err := p.Allocate(); err != nil || id != want {
+			t.Errorf("expected page %d and got %d, %v", want, id, err)
+		}
+	}
+}
+
+func TestPagerInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "other.db")
+	_ = os.WriteFile(path, bytes.Repeat([]byte("x"), 4096), 0644)
+	if _, err := OpenPager(path, DefaultPageSize); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected ErrCorrupt and got %v", err)
+	}
+	if _, err := OpenPager(filepath.Join(t.TempDir(), "small.db"), 100); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+}
@@ -0,0 +1,86 @@
+package bptree
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// 这组测试需要配合 go test -race 运行:
+// 每个goroutine操作自己独立的tree 不同的tree之间不能有任何共享的可变状态
+
+func TestParallelIndependentTrees(t *testing.T) {
+	orders := []int{3, 4, 5, 16, 64}
+
+	var wg sync.WaitGroup
+	for i, order := range orders {
+		wg.Add(1)
+		go func(seed int64, order int) {
+			defer wg.Done()
+			tree := NewTree[int, string](WithOrder(order))
+			r := rand.New(rand.NewSource(seed))
+			keys := r.Perm(2000)
+
+			for _, key := range keys {
+				if err := tree.Insert(key, fmt.Sprint(key)); err != nil {
+					t.Errorf("order %d: %s", order, err)
+					return
+				}
+			}
+			for _, key := range keys {
+				if rec, err := tree.Find(key); err != nil || rec.Value != fmt.Sprint(key) {
+					t.Errorf("order %d: find %d failed: %v", order, key, err)
+					return
+				}
+			}
+			if found, _ := tree.FindRange(0, 2000); len(found) != 2000 {
+				t.Errorf("order %d: expected 2000 keys and got %d", order, len(found))
+			}
+		}(int64(i), order)
+	}
+	wg.Wait()
+}
+
+// 分裂时的参数来自各自的tree 不同阶数的树并行构建 结果互不影响
+func TestParallelBuildDifferentOrders(t *testing.T) {
+	var wg sync.WaitGroup
+	trees := make([]*Tree[int, int], 8)
+	for i := range trees {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			trees[i] = NewTree[int, int](WithOrder(3+i*5), WithFillFactor(float64(i+1)/8))
+			for key := 0; key < 3000; key++ {
+				_ = trees[i].Insert(key, key)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, tree := range trees {
+		checkOccupancy(t, tree)
+		keys, _ := tree.FindRange(0, 3000)
+		if len(keys) != 3000 {
+			t.Errorf("order %d: expected 3000 keys and got %d", tree.Order(), len(keys))
+		}
+	}
+}
+
+// PrintTree内部的遍历队列是函数内的局部变量 并行打印不会相互干扰
+func TestParallelPrint(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tree := NewTree[int, int]()
+			for key := 0; key < 8; key++ {
+				_ = tree.Insert(key*10+i, key)
+			}
+			tree.PrintTree()
+			tree.PrintLeaves()
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,155 @@
+package bptree
+
+import "iter"
+
+// *********************** 范围查询 ***********************
+/*
+RangeQuery描述一个范围查询:
+1. Lower/Upper分别是下界和上界,每个边界可以是包含(Included)、不包含(Excluded)或者不限制(Unbounded)。
+   时间窗口一类的半开区间[lo, hi)写作 RangeQuery[K]{Lower: Included(lo), Upper: Excluded(hi)}。
+2. Offset跳过范围内最前面的若干条数据,Limit限制最多返回的数据条数(0表示不限制),用于分页。
+FindRange(keyMin, keyMax)等价于两端都是Included的查询。
+*/
+
+// BoundKind :边界类型
+type BoundKind int
+
+const (
+	BoundUnbounded BoundKind = iota // 不限制
+	BoundIncluded                   // 包含边界上的key
+	BoundExcluded                   // 不包含边界上的key
+)
+
+// Bound :范围查询的边界 零值表示不限制
+type Bound[K any] struct {
+	Key  K
+	Kind BoundKind
+}
+
+// Included :包含key的边界
+func Included[K any](key K) Bound[K] {
+	return Bound[K]{Key: key, Kind: BoundIncluded}
+}
+
+// Excluded :不包含key的边界
+func Excluded[K any](key K) Bound[K] {
+	return Bound[K]{Key: key, Kind: BoundExcluded}
+}
+
+// Unbounded :不限制的边界
+func Unbounded[K any]() Bound[K] {
+	return Bound[K]{}
+}
+
+// RangeQuery :范围查询条件
+type RangeQuery[K any] struct {
+	Lower  Bound[K] // 下界
+	Upper  Bound[K] // 上界
+	Offset int      // 跳过的数据条数
+	Limit  int      // 最多返回的数据条数 0表示不限制
+}
+
+// key不小于下界
+func (q *RangeQuery[K]) afterLower(key K, compare func(a, b K) int) bool {
+	switch q.Lower.Kind {
+	case BoundIncluded:
+		return compare(key, q.Lower.Key) >= 0
+	case BoundExcluded:
+		return compare(key, q.Lower.Key) > 0
+	}
+	return true
+}
+
+// key不大于上界
+func (q *RangeQuery[K]) beforeUpper(key K, compare func(a, b K) int) bool {
+	switch q.Upper.Kind {
+	case BoundIncluded:
+		return compare(key, q.Upper.Key) <= 0
+	case BoundExcluded:
+		return compare(key, q.Upper.Key) < 0
+	}
+	return true
+}
+
+// 沿叶子链表收集数据时的状态 skip是还需要跳过的数据条数
+type rangeCollector[K any, V any] struct {
+	q       *RangeQuery[K]
+	skip    int
+	keys    []K
+	records []*Record[V]
+}
+
+func newRangeCollector[K any, V any](q *RangeQuery[K]) *rangeCollector[K, V] {
+	return &rangeCollector[K, V]{q: q, skip: q.Offset, keys: make([]K, 0), records: make([]*Record[V], 0)}
+}
+
+// 处理一个范围内的key 返回false表示已经达到Limit
+func (c *rangeCollector[K, V]) add(key K, record *Record[V]) bool {
+	if c.skip > 0 {
+		c.skip--
+		return true
+	}
+	c.keys = append(c.keys, key)
+	c.records = append(c.records, record)
+	return c.q.Limit <= 0 || len(c.keys) < c.q.Limit
+}
+
+// FindRangeQuery :按查询条件范围查找 结果按key从小到大排列
+func (t *Tree[K, V]) FindRangeQuery(q RangeQuery[K]) ([]K, []*Record[V]) {
+	if t.concurrent {
+		return t.concurrentFindRange(q)
+	}
+
+	var n *Node[K, V]
+	if q.Lower.Kind == BoundUnbounded {
+		n = t.edgeLeaf(false)
+	} else {
+		n = t.findLeaf(q.Lower.Key)
+	}
+
+	c := newRangeCollector[K, V](&q)
+	for ; n != nil; n = n.Next {
+		for i, key := range n.Keys {
+			if !q.afterLower(key, t.compare) {
+				continue
+			}
+			if !q.beforeUpper(key, t.compare) || !c.add(key, n.Pointers[i].(*Record[V])) {
+				return c.keys, c.records
+			}
+		}
+	}
+	return c.keys, c.records
+}
+
+// Scan :按查询条件遍历 可以直接用于for range
+func (t *Tree[K, V]) Scan(q RangeQuery[K]) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		c := t.Cursor()
+		var ok bool
+		if q.Lower.Kind == BoundUnbounded {
+			ok = c.SeekFirst()
+		} else {
+			ok = c.Seek(q.Lower.Key)
+		}
+
+		skip, count := q.Offset, 0
+		for ; ok; ok = c.Next() {
+			if !q.afterLower(c.Key(), t.compare) {
+				continue
+			}
+			if !q.beforeUpper(c.Key(), t.compare) {
+				return
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if !yield(c.Key(), c.Value()) {
+				return
+			}
+			if count++; q.Limit > 0 && count >= q.Limit {
+				return
+			}
+		}
+	}
+}
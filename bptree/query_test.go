@@ -0,0 +1,111 @@
+package bptree
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// 在排好序的切片上计算期望的查询结果
+func expectRange(sorted []int, q RangeQuery[int]) []int {
+	compare := func(a, b int) int { return a - b }
+	want := make([]int, 0)
+	skip := q.Offset
+	for _, key := range sorted {
+		if !q.afterLower(key, compare) || !q.beforeUpper(key, compare) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if q.Limit > 0 && len(want) >= q.Limit {
+			break
+		}
+		want = append(want, key)
+	}
+	return want
+}
+
+func randomBound(r interface{ Intn(int) int }, n int) Bound[int] {
+	key := r.Intn(n+20) - 10
+	switch r.Intn(3) {
+	case 0:
+		return Unbounded[int]()
+	case 1:
+		return Included(key)
+	}
+	return Excluded(key)
+}
+
+// 随机的边界类型、Offset、Limit与排好序的切片对比
+func TestRangeQueryProperty(t *testing.T) {
+	for _, opts := range [][]Option{{WithOrder(4)}, {WithOrder(5), WithConcurrency()}} {
+		r := testRand(t)
+		tree := NewTree[int, int](opts...)
+		sorted := make([]int, 0)
+		for len(sorted) < 500 {
+			key := r.Intn(2000)
+			if tree.Insert(key, key) == nil {
+				sorted = append(sorted, key)
+			}
+		}
+		sort.Ints(sorted)
+
+		for i := 0; i < 2000; i++ {
+			q := RangeQuery[int]{Lower: randomBound(r, 2000), Upper: randomBound(r, 2000)}
+			if r.Intn(2) == 0 {
+				q.Offset = r.Intn(20)
+			}
+			if r.Intn(2) == 0 {
+				q.Limit = r.Intn(50)
+			}
+			want := expectRange(sorted, q)
+
+			keys, records := tree.FindRangeQuery(q)
+			if !reflect.DeepEqual(keys, want) {
+				t.Fatalf("FindRangeQuery(%+v): expected %v and got %v", q, want, keys)
+			}
+			for j := range keys {
+				if records[j].Value != keys[j] {
+					t.Fatalf("unexpected value %d for key %d", records[j].Value, keys[j])
+				}
+			}
+
+			scanned := make([]int, 0)
+			for key := range tree.Scan(q) {
+				scanned = append(scanned, key)
+			}
+			if !reflect.DeepEqual(scanned, want) {
+				t.Fatalf("Scan(%+v): expected %v and got %v", q, want, scanned)
+			}
+		}
+	}
+}
+
+// 范围的上界落在某个叶子节点中间时 仍然要返回这个叶子中不超过上界的key
+func TestFindRangeStraddle(t *testing.T) {
+	for _, opts := range [][]Option{{WithOrder(4)}, {WithOrder(4), WithConcurrency()}} {
+		tree := NewTree[int, int](opts...)
+		for key := 0; key < 100; key++ {
+			_ = tree.Insert(key, key)
+		}
+		for lo := 0; lo < 100; lo += 7 {
+			for hi := lo; hi < 100; hi += 5 {
+				keys, _ := tree.FindRange(lo, hi)
+				if len(keys) != hi-lo+1 || keys[0] != lo || keys[len(keys)-1] != hi {
+					t.Fatalf("FindRange(%d, %d): unexpected keys %v", lo, hi, keys)
+				}
+			}
+		}
+
+		// 半开区间
+		keys, _ := tree.FindRangeQuery(RangeQuery[int]{Lower: Included(10), Upper: Excluded(20)})
+		if len(keys) != 10 || keys[0] != 10 || keys[9] != 19 {
+			t.Errorf("unexpected half-open range %v", keys)
+		}
+		if keys, _ := tree.FindRange(50, 10); len(keys) != 0 {
+			t.Errorf("expected empty range and got %v", keys)
+		}
+	}
+}
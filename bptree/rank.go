@@ -0,0 +1,160 @@
+package bptree
+
+import "sync/atomic"
+
+// *********************** 顺序统计 ***********************
+/*
+每个节点的size记录子树中的数据数量(叶子节点就是key的数量),Rank/Select/CountRange只需要从根节点下降一次:
+1. 叶子节点增加/删除数据时,叶子节点和所有祖先的size加减1(resize)。
+2. 分裂、借调、合并只是在兄弟节点之间移动数据,祖先的size不变,参与的节点按子节点重新计算(recount)。
+3. 并发模式下size用原子操作读写:写操作持有整条路径的latch完成resize(见concurrent.go),
+   读操作自上而下加读latch,结果和同时进行的修改之间不保证原子性。
+*/
+
+// Len :数据数量
+func (t *Tree[K, V]) Len() int {
+	if t.concurrent {
+		t.cowLatch.RLock()
+		defer t.cowLatch.RUnlock()
+		t.rootLatch.RLock()
+		defer t.rootLatch.RUnlock()
+	}
+	if t.Root == nil {
+		return 0
+	}
+	return int(t.Root.subtreeSize())
+}
+
+// Rank :小于key的数据数量 key存在时就是key的排名(从0开始)
+func (t *Tree[K, V]) Rank(key K) int {
+	return t.rank(key, false)
+}
+
+// Select :第i小(从0开始)的key和Record i超出范围时ok为false
+func (t *Tree[K, V]) Select(i int) (key K, record *Record[V], ok bool) {
+	if i < 0 {
+		return
+	}
+	t.descend(func(n *Node[K, V]) int {
+		c := 0
+		for ; c < n.Count; c++ {
+			size := int(n.Pointers[c].(*Node[K, V]).subtreeSize())
+			if i < size {
+				break
+			}
+			i -= size
+		}
+		return c
+	}, func(leaf *Node[K, V]) {
+		if i < leaf.Count {
+			key, record, ok = leaf.Keys[i], leaf.Pointers[i].(*Record[V]), true
+		}
+	})
+	return
+}
+
+// CountRange :keyMin<=key<=keyMax的数据数量 与len(FindRange(keyMin, keyMax))相同
+func (t *Tree[K, V]) CountRange(keyMin, keyMax K) int {
+	if t.compare(keyMin, keyMax) > 0 {
+		return 0
+	}
+	// 并发模式下两次下降之间可能有删除 不返回负数
+	return max(t.rank(keyMax, true)-t.rank(keyMin, false), 0)
+}
+
+// 小于key(inclusive为true时小于等于key)的数据数量
+func (t *Tree[K, V]) rank(key K, inclusive bool) int {
+	rank := 0
+	t.descend(func(n *Node[K, V]) int {
+		c := n.getChildIndex(key, t.compare)
+		for _, p := range n.Pointers[:c] {
+			rank += int(p.(*Node[K, V]).subtreeSize())
+		}
+		return c
+	}, func(leaf *Node[K, V]) {
+		i := leaf.getInsertIndex(key, t.compare)
+		if inclusive && i < leaf.Count && t.compare(leaf.Keys[i], key) == 0 {
+			i++
+		}
+		rank += i
+	})
+	return rank
+}
+
+// 从根节点下降到叶子节点 next返回下一层子节点的index visit访问叶子节点
+/*
+并发模式下自上而下加读latch,visit返回之前一直持有当前节点的读latch。
+*/
+func (t *Tree[K, V]) descend(next func(n *Node[K, V]) int, visit func(leaf *Node[K, V])) {
+	if !t.concurrent {
+		n := t.Root
+		if n == nil {
+			return
+		}
+		for !n.IsLeaf {
+			n = n.Pointers[next(n)].(*Node[K, V])
+		}
+		visit(n)
+		return
+	}
+
+	t.cowLatch.RLock()
+	defer t.cowLatch.RUnlock()
+	t.rootLatch.RLock()
+	n := t.Root
+	if n == nil {
+		t.rootLatch.RUnlock()
+		return
+	}
+	n.latch.RLock()
+	t.rootLatch.RUnlock()
+
+	for !n.IsLeaf {
+		child := n.Pointers[next(n)].(*Node[K, V])
+		child.latch.RLock()
+		n.latch.RUnlock()
+		n = child
+	}
+	defer n.latch.RUnlock()
+	visit(n)
+}
+
+// 子树中的数据数量
+func (n *Node[K, V]) subtreeSize() int64 {
+	return atomic.LoadInt64(&n.size)
+}
+
+// 按子节点重新计算size 分裂、借调、合并之后调用
+func (n *Node[K, V]) recount() {
+	if n.IsLeaf {
+		atomic.StoreInt64(&n.size, int64(len(n.Keys)))
+		return
+	}
+	var size int64
+	for _, p := range n.Pointers {
+		size += p.(*Node[K, V]).subtreeSize()
+	}
+	atomic.StoreInt64(&n.size, size)
+}
+
+// 叶子节点增加/删除数据 叶子节点和所有祖先的size加上delta
+/*
+增加时自下而上、删除时自上而下修改,并发读取时子节点的size之和总是不小于父节点的size,
+Select按子节点的size下降不会因为修改到一半而找不到数据。
+*/
+func (t *Tree[K, V]) resize(leaf *Node[K, V], delta int64) {
+	if delta > 0 {
+		for n := leaf; n != nil; n = n.Parent {
+			atomic.AddInt64(&n.size, delta)
+		}
+		return
+	}
+
+	path := make([]*Node[K, V], 0)
+	for n := leaf; n != nil; n = n.Parent {
+		path = append(path, n)
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		atomic.AddInt64(&path[i].size, delta)
+	}
+}
@@ -0,0 +1,169 @@
+package bptree
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// 检查每个节点的size都等于子树中的数据数量
+func checkSizes[K any, V any](t *testing.T, tree *Tree[K, V]) {
+	t.Helper()
+	var count func(n *Node[K, V]) int64
+	count = func(n *Node[K, V]) int64 {
+		size := int64(n.Count)
+		if !n.IsLeaf {
+			size = 0
+			for _, p := range n.Pointers {
+				size += count(p.(*Node[K, V]))
+			}
+		}
+		if n.subtreeSize() != size {
+			t.Errorf("node %v has size %d but %d records", n.Keys, n.subtreeSize(), size)
+		}
+		return size
+	}
+	if tree.Root != nil {
+		count(tree.Root)
+	}
+}
+
+// 与排好序的切片对比Rank/Select/CountRange
+func checkRank(t *testing.T, tree *Tree[int, int], sorted []int) {
+	t.Helper()
+	checkSizes(t, tree)
+	if tree.Len() != len(sorted) {
+		t.Fatalf("expected %d keys and got %d", len(sorted), tree.Len())
+	}
+	for i, key := range sorted {
+		if rank := tree.Rank(key); rank != i {
+			t.Fatalf("expected rank %d for key %d and got %d", i, key, rank)
+		}
+		if rank := tree.Rank(key + 1); key+1 < 3000 && rank != sort.SearchInts(sorted, key+1) {
+			t.Fatalf("unexpected rank %d for missing key %d", rank, key+1)
+		}
+		if k, r, ok := tree.Select(i); !ok || k != key || r.Value != key {
+			t.Fatalf("expected key %d at %d and got %d", key, i, k)
+		}
+	}
+	if _, _, ok := tree.Select(len(sorted)); ok {
+		t.Errorf("expected no key at %d", len(sorted))
+	}
+	if _, _, ok := tree.Select(-1); ok {
+		t.Errorf("expected no key at -1")
+	}
+
+	r := testRand(t)
+	for i := 0; i < 200; i++ {
+		lo, hi := r.Intn(3100)-50, r.Intn(3100)-50
+		want := sort.SearchInts(sorted, hi+1) - sort.SearchInts(sorted, lo)
+		if lo > hi {
+			want = 0
+		}
+		if got := tree.CountRange(lo, hi); got != want {
+			t.Fatalf("CountRange(%d, %d): expected %d and got %d", lo, hi, want, got)
+		}
+	}
+}
+
+func TestRankSelect(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithOrder(4)}, {WithOrder(4), WithConcurrency()}, {WithOrder(64)}, {WithOrder(64), WithConcurrency()},
+	} {
+		tree := NewTree[int, int](opts...)
+		checkRank(t, tree, nil)
+
+		r := testRand(t)
+		keys := make(map[int]bool)
+		for len(keys) < 1000 {
+			key := r.Intn(3000)
+			if tree.Insert(key, key) == nil {
+				keys[key] = true
+			}
+		}
+		sorted := make([]int, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Ints(sorted)
+		checkRank(t, tree, sorted)
+
+		rest := make([]int, 0)
+		for i, key := range sorted {
+			if i%3 == 0 {
+				rest = append(rest, key)
+			} else if err := tree.Delete(key); err != nil {
+				t.Fatalf("%s", err)
+			}
+		}
+		checkRank(t, tree, rest)
+	}
+}
+
+// 快照复制节点、BulkLoad构建的节点同样维护size
+func TestRankSnapshotAndBulkLoad(t *testing.T) {
+	sorted := make([]int, 500)
+	for i := range sorted {
+		sorted[i] = i
+	}
+	tree := NewTree[int, int](WithOrder(5))
+	if err := tree.BulkLoad(slices.All(sorted), 0.7); err != nil {
+		t.Fatalf("%s", err)
+	}
+	checkRank(t, tree, sorted)
+
+	snap := tree.Snapshot()
+	for key := 500; key < 600; key++ {
+		_ = tree.Insert(key, key)
+		sorted = append(sorted, key)
+	}
+	checkRank(t, tree, sorted)
+	if keys, _ := snap.FindRange(0, 1000); len(keys) != 500 {
+		t.Errorf("expected 500 keys in snapshot and got %d", len(keys))
+	}
+}
+
+// 并发写的同时读取排名 结束后size仍然准确
+func TestRankConcurrent(t *testing.T) {
+	for _, order := range []int{8, 64} {
+		tree := NewTree[int, int](WithOrder(order), WithConcurrency())
+		for key := 0; key < 3000; key += 3 {
+			_ = tree.Insert(key, key)
+		}
+
+		var wg sync.WaitGroup
+		for w := 1; w <= 2; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for key := w; key < 3000; key += 3 {
+					_ = tree.Insert(key, key)
+				}
+				for key := w; key < 3000; key += 3 {
+					_ = tree.Delete(key)
+				}
+			}(w)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if n := tree.CountRange(-1, 3000); n < 1000 || n > 3000 {
+					t.Errorf("unexpected count %d", n)
+					return
+				}
+				if _, _, ok := tree.Select(999); !ok {
+					t.Errorf("expected key at 999")
+					return
+				}
+			}
+		}()
+		wg.Wait()
+
+		if tree.Len() != 1000 || tree.CountRange(0, 2999) != 1000 {
+			t.Errorf("expected 1000 keys and got %d", tree.Len())
+		}
+		checkSizes(t, tree)
+	}
+}
@@ -0,0 +1,148 @@
+package bptree
+
+import (
+	"slices"
+	"sync/atomic"
+)
+
+// *********************** 快照(copy-on-write) ***********************
+/*
+Snapshot返回某一时刻的只读视图,之后的Insert/Delete不会影响它:
+1. 每个节点记录创建时tree的版本(gen),Snapshot把tree的版本加1,之前的节点都变成被快照共享的节点。
+2. 修改共享节点之前先复制(writable),复制节点要修改父节点的指针,所以父节点也要先复制,
+   一次写操作只复制从根节点到被修改节点的路径(path copying),没有被修改的子树仍然共享。
+3. 快照只读取节点的Keys/Pointers/IsLeaf,这些字段在共享节点上永远不会被修改;
+   Parent和叶子链表的Next/Prev只属于当前的tree,复制节点时直接修改共享节点上的这些指针。
+4. Record也不会被原地修改,快照和tree可以返回同一个Record。
+
+快照不需要释放,不再使用之后被共享的节点由GC回收。
+非并发模式下Snapshot必须和写操作在同一个goroutine中调用,得到的快照可以交给其他goroutine读取;
+并发模式下Snapshot可以在任意goroutine调用,复制共享节点的写操作会独占整棵树(见concurrent.go)。
+*/
+
+// Snapshot :tree在某一时刻的只读视图 可以被多个goroutine同时读取
+type Snapshot[K any, V any] struct {
+	root    *Node[K, V]
+	compare func(a, b K) int
+}
+
+// Snapshot :创建快照
+func (t *Tree[K, V]) Snapshot() *Snapshot[K, V] {
+	if t.concurrent {
+		// 等待正在进行的操作结束 之后的写操作都能看到新的版本
+		t.cowLatch.Lock()
+		defer t.cowLatch.Unlock()
+	}
+
+	t.gen++
+	return &Snapshot[K, V]{root: t.Root, compare: t.compare}
+}
+
+// 节点被快照共享
+func (t *Tree[K, V]) shared(n *Node[K, V]) bool {
+	return n.gen != t.gen
+}
+
+// 返回可以修改的节点:共享节点先复制 并替换父节点中的指针(父节点同样先复制)
+func (t *Tree[K, V]) writable(n *Node[K, V]) *Node[K, V] {
+	if !t.shared(n) {
+		return n
+	}
+
+	c := &Node[K, V]{
+		Keys:     slices.Clone(n.Keys),
+		Pointers: slices.Clone(n.Pointers),
+		Parent:   n.Parent,
+		IsLeaf:   n.IsLeaf,
+		Count:    n.Count,
+		Next:     n.Next,
+		Prev:     n.Prev,
+		gen:      t.gen,
+		size:     atomic.LoadInt64(&n.size),
+	}
+
+	if n.Parent == nil {
+		t.Root = c
+	} else {
+		c.Parent = t.writable(n.Parent)
+		c.Parent.Pointers[c.Parent.getPointerIndex(n)] = c
+	}
+
+	if c.IsLeaf {
+		if c.Prev != nil {
+			c.Prev.Next = c
+		}
+		if c.Next != nil {
+			c.Next.Prev = c
+		}
+	} else {
+		for _, p := range c.Pointers {
+			p.(*Node[K, V]).Parent = c
+		}
+	}
+	return c
+}
+
+// Find :在快照中查找key key不存在时返回ErrKeyNotFound
+func (s *Snapshot[K, V]) Find(key K) (*Record[V], error) {
+	if r := s.lookup(key); r != nil {
+		return r, nil
+	}
+	return nil, keyError(ErrKeyNotFound, key)
+}
+
+// Get :在快照中查找key的value 不存在时ok为false
+func (s *Snapshot[K, V]) Get(key K) (value V, ok bool) {
+	if r := s.lookup(key); r != nil {
+		return r.Value, true
+	}
+	return value, false
+}
+
+func (s *Snapshot[K, V]) lookup(key K) *Record[V] {
+	n := s.root
+	if n == nil {
+		return nil
+	}
+	for !n.IsLeaf {
+		n, _ = n.Pointers[n.getChildIndex(key, s.compare)].(*Node[K, V])
+	}
+	return n.getRecord(key, s.compare)
+}
+
+// FindRange :在快照中范围查找 返回keyMin<=key<=keyMax的数据
+/*
+快照中叶子链表指针可能已经被tree修改,所以不沿Next遍历,而是从根节点按子树范围递归。
+*/
+func (s *Snapshot[K, V]) FindRange(keyMin K, keyMax K) ([]K, []*Record[V]) {
+	keys := make([]K, 0)
+	records := make([]*Record[V], 0)
+	if s.root != nil {
+		keys, records = s.findRange(s.root, keyMin, keyMax, keys, records)
+	}
+	return keys, records
+}
+
+func (s *Snapshot[K, V]) findRange(n *Node[K, V], keyMin, keyMax K, keys []K, records []*Record[V]) ([]K, []*Record[V]) {
+	if n.IsLeaf {
+		for i, key := range n.Keys {
+			if s.compare(key, keyMin) >= 0 && s.compare(key, keyMax) <= 0 {
+				keys = append(keys, key)
+				records = append(records, n.Pointers[i].(*Record[V]))
+			}
+		}
+		return keys, records
+	}
+
+	// 第i个子树中的key在[Keys[i-1], Keys[i])之间
+	for i, p := range n.Pointers {
+		if i < len(n.Keys) && s.compare(n.Keys[i], keyMin) <= 0 {
+			continue
+		}
+		if i > 0 && s.compare(n.Keys[i-1], keyMax) > 0 {
+			break
+		}
+		keys, records = s.findRange(p.(*Node[K, V]), keyMin, keyMax, keys, records)
+	}
+	return keys, records
+}
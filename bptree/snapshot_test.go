@@ -0,0 +1,237 @@
+package bptree
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// 检查快照中的数据和创建快照时一致
+func checkSnapshot(t *testing.T, snap *Snapshot[int, int], want []int) {
+	t.Helper()
+	keys, records := snap.FindRange(-1, 1<<30)
+	if len(keys) != len(want) || (len(keys) > 0 && !reflect.DeepEqual(keys, want)) {
+		t.Fatalf("expected %d keys in snapshot and got %d", len(want), len(keys))
+	}
+	for i, key := range keys {
+		if records[i].Value != key {
+			t.Fatalf("unexpected value %d for key %d", records[i].Value, key)
+		}
+	}
+	for _, key := range want {
+		if rec, err := snap.Find(key); err != nil || rec.Value != key {
+			t.Fatalf("find %d in snapshot failed: %v", key, err)
+		}
+	}
+}
+
+// 复制节点之后 Parent指针和叶子链表都指向tree中当前的节点
+func checkLinks[K any, V any](t *testing.T, tree *Tree[K, V]) {
+	t.Helper()
+	leaves := make([]*Node[K, V], 0)
+	var walk func(n *Node[K, V])
+	walk = func(n *Node[K, V]) {
+		if n.IsLeaf {
+			leaves = append(leaves, n)
+			return
+		}
+		for _, p := range n.Pointers {
+			child := p.(*Node[K, V])
+			if child.Parent != n {
+				t.Fatalf("broken Parent pointer")
+			}
+			walk(child)
+		}
+	}
+	if tree.Root == nil {
+		return
+	}
+	walk(tree.Root)
+
+	for i, leaf := range leaves {
+		if i > 0 && leaf.Prev != leaves[i-1] || i == 0 && leaf.Prev != nil {
+			t.Fatalf("leaf %d: broken Prev pointer", i)
+		}
+		if i < len(leaves)-1 && leaf.Next != leaves[i+1] || i == len(leaves)-1 && leaf.Next != nil {
+			t.Fatalf("leaf %d: broken Next pointer", i)
+		}
+	}
+}
+
+// 多个快照之后继续插入删除 每个快照都保持创建时的数据
+func TestSnapshotIsolation(t *testing.T) {
+	tree := NewTree[int, int](WithOrder(64))
+	model := make(map[int]string)
+	r := testRand(t)
+
+	snaps := make([]*Snapshot[int, int], 0)
+	states := make([][]int, 0)
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 1000; i++ {
+			key := r.Intn(1500)
+			if r.Intn(3) > 0 {
+				if tree.Insert(key, key) == nil {
+					model[key] = ""
+				}
+			} else if tree.Delete(key) == nil {
+				delete(model, key)
+			}
+		}
+		snaps = append(snaps, tree.Snapshot())
+		states = append(states, sortedKeys(model))
+	}
+
+	for i, snap := range snaps {
+		checkSnapshot(t, snap, states[i])
+	}
+	checkLeafChain(t, tree, len(model))
+	checkLinks(t, tree)
+	checkOccupancy(t, tree)
+
+	// 删空tree之后快照不受影响
+	for key := range model {
+		if err := tree.Delete(key); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+	if tree.Root != nil {
+		t.Errorf("expected empty tree")
+	}
+	checkSnapshot(t, snaps[len(snaps)-1], states[len(states)-1])
+}
+
+func TestSnapshotRange(t *testing.T) {
+	tree := NewTree[int, int](WithOrder(4))
+	for key := 0; key < 500; key += 2 {
+		_ = tree.Insert(key, key)
+	}
+	snap := tree.Snapshot()
+	for key := 1; key < 500; key += 2 {
+		_ = tree.Insert(key, key)
+	}
+
+	keys, _ := snap.FindRange(101, 120)
+	want := []int{102, 104, 106, 108, 110, 112, 114, 116, 118, 120}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("expected %v and got %v", want, keys)
+	}
+	if keys, _ := snap.FindRange(600, 700); len(keys) != 0 {
+		t.Errorf("expected no keys and got %v", keys)
+	}
+	if _, err := snap.Find(101); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+	if keys, _ := (&Snapshot[int, int]{compare: tree.compare}).FindRange(0, 10); len(keys) != 0 {
+		t.Errorf("expected no keys in empty snapshot")
+	}
+}
+
+// 快照之后的一次插入只复制根节点到叶子节点的路径
+func TestSnapshotPathCopy(t *testing.T) {
+	tree := NewTree[int, int](WithOrder(4))
+	for key := 0; key < 1000; key++ {
+		_ = tree.Insert(key*10, key)
+	}
+	_ = tree.Snapshot()
+	_ = tree.Insert(5005, 0)
+
+	copied := 0
+	var walk func(n *Node[int, int])
+	walk = func(n *Node[int, int]) {
+		if !tree.shared(n) {
+			copied++
+		}
+		if !n.IsLeaf {
+			for _, p := range n.Pointers {
+				walk(p.(*Node[int, int]))
+			}
+		}
+	}
+	walk(tree.Root)
+	if copied != tree.height() {
+		t.Errorf("expected %d copied nodes and got %d", tree.height(), copied)
+	}
+	checkLeafChain(t, tree, 1001)
+	checkLinks(t, tree)
+}
+
+// 写操作持续进行时 其他goroutine读取快照
+func TestSnapshotConcurrentReaders(t *testing.T) {
+	for _, opts := range [][]Option{{WithOrder(8)}, {WithOrder(8), WithConcurrency()}} {
+		tree := NewTree[int, int](opts...)
+		for key := 0; key < 2000; key++ {
+			_ = tree.Insert(key, key)
+		}
+		snap := tree.Snapshot()
+		want, _ := snap.FindRange(0, 2000)
+
+		var wg sync.WaitGroup
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 20; i++ {
+					if keys, _ := snap.FindRange(0, 2000); len(keys) != len(want) {
+						t.Errorf("expected %d keys and got %d", len(want), len(keys))
+						return
+					}
+				}
+			}()
+		}
+
+		// 并发模式下多个goroutine同时写入和创建快照
+		writers := 1
+		if tree.concurrent {
+			writers = 4
+		}
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for key := 2000 + w; key < 6000; key += writers {
+					_ = tree.Insert(key, key)
+					if key%500 == 0 && tree.concurrent {
+						_ = tree.Snapshot()
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		checkSnapshot(t, snap, want)
+		checkLeafChain(t, tree, 6000)
+		checkLinks(t, tree)
+	}
+}
+
+// 并发删除遇到共享节点时独占复制 快照不受影响
+func TestSnapshotConcurrentDelete(t *testing.T) {
+	tree := NewTree[int, int](WithOrder(64), WithConcurrency())
+	for key := 0; key < 1500; key++ {
+		_ = tree.Insert(key, key)
+	}
+	snap := tree.Snapshot()
+	want, _ := snap.FindRange(0, 1500)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for key := w; key < 1500; key += 4 {
+				if key%3 == 0 {
+					continue
+				}
+				if err := tree.Delete(key); err != nil {
+					t.Errorf("delete %d: %s", key, err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	checkSnapshot(t, snap, want)
+	checkLeafChain(t, tree, 500)
+	checkLinks(t, tree)
+}
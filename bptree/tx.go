@@ -0,0 +1,197 @@
+package bptree
+
+import (
+	"errors"
+	"slices"
+)
+
+// *********************** 事务(MVCC) ***********************
+/*
+事务提供快照隔离(snapshot isolation):
+1. Begin创建一个快照,事务中的所有读操作都读这个快照,看不到之后其他写操作的结果。
+2. 事务中的写操作只记录在事务自己的写集合中,事务内部的读操作能看到自己的写。
+3. Commit时检查写集合中的每个key:当前tree中的Record和快照中的不是同一个(被其他写操作插入、删除或者替换过),
+   说明有冲突,返回ErrConflict并且不写入任何数据(先提交的获胜)。
+4. 没有冲突则一次性写入tree,并发模式下写入期间独占整棵树,其他读操作不会看到写了一半的状态。
+每个版本由快照的path copying保存,Record不会被原地修改,所以用Record的指针区分版本。
+只检查写-写冲突,不能防止write skew。
+
+Begin/Commit在非并发模式下需要和其他写操作在同一个goroutine中调用,
+一个事务只能在一个goroutine中使用。
+*/
+
+// ErrConflict :提交时发现其他事务已经修改了同一个key
+var ErrConflict = errors.New("bptree: transaction conflict")
+
+// ErrTxDone :事务已经提交或者回滚
+var ErrTxDone = errors.New("bptree: transaction has already been committed or rolled back")
+
+// Tx :事务
+type Tx[K any, V any] struct {
+	t      *Tree[K, V]
+	snap   *Snapshot[K, V]
+	writes []txWrite[K, V] // 按key排序的写集合
+	done   bool
+}
+
+// 事务中的一次写 record为nil表示删除
+type txWrite[K any, V any] struct {
+	key    K
+	record *Record[V]
+}
+
+// Begin :开始一个事务
+func (t *Tree[K, V]) Begin() *Tx[K, V] {
+	return &Tx[K, V]{t: t, snap: t.Snapshot()}
+}
+
+// 写集合中key的位置
+func (tx *Tx[K, V]) search(key K) (int, bool) {
+	return slices.BinarySearchFunc(tx.writes, key, func(w txWrite[K, V], key K) int {
+		return tx.t.compare(w.key, key)
+	})
+}
+
+func (tx *Tx[K, V]) set(key K, record *Record[V]) {
+	i, found := tx.search(key)
+	if found {
+		tx.writes[i].record = record
+		return
+	}
+	tx.writes = slices.Insert(tx.writes, i, txWrite[K, V]{key: key, record: record})
+}
+
+// Find :在事务中查找key
+func (tx *Tx[K, V]) Find(key K) (*Record[V], error) {
+	if tx.done {
+		return nil, ErrTxDone
+	}
+	if i, found := tx.search(key); found {
+		if tx.writes[i].record == nil {
+			return nil, keyError(ErrKeyNotFound, key)
+		}
+		return tx.writes[i].record, nil
+	}
+	return tx.snap.Find(key)
+}
+
+// FindRange :在事务中范围查找 返回keyMin<=key<=keyMax的数据
+func (tx *Tx[K, V]) FindRange(keyMin K, keyMax K) ([]K, []*Record[V], error) {
+	if tx.done {
+		return nil, nil, ErrTxDone
+	}
+	snapKeys, snapRecords := tx.snap.FindRange(keyMin, keyMax)
+	from, _ := tx.search(keyMin)
+
+	// 合并快照和写集合中的数据 key相同时以写集合为准
+	keys := make([]K, 0, len(snapKeys))
+	records := make([]*Record[V], 0, len(snapKeys))
+	i, j := 0, from
+	for i < len(snapKeys) || (j < len(tx.writes) && tx.t.compare(tx.writes[j].key, keyMax) <= 0) {
+		if j >= len(tx.writes) || tx.t.compare(tx.writes[j].key, keyMax) > 0 {
+			keys = append(keys, snapKeys[i])
+			records = append(records, snapRecords[i])
+			i++
+			continue
+		}
+
+		c := -1
+		if i < len(snapKeys) {
+			c = tx.t.compare(tx.writes[j].key, snapKeys[i])
+		}
+		if c > 0 {
+			keys = append(keys, snapKeys[i])
+			records = append(records, snapRecords[i])
+			i++
+			continue
+		}
+		if c == 0 {
+			i++
+		}
+		if w := tx.writes[j]; w.record != nil {
+			keys = append(keys, w.key)
+			records = append(records, w.record)
+		}
+		j++
+	}
+	return keys, records, nil
+}
+
+// Insert :在事务中插入key
+func (tx *Tx[K, V]) Insert(key K, value V) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	if _, err := tx.Find(key); err == nil {
+		return keyError(ErrKeyExists, key)
+	}
+
+	pointer, err := newRecord(value)
+	if err != nil {
+		return err
+	}
+	tx.set(key, pointer)
+	return nil
+}
+
+// Delete :在事务中删除key
+func (tx *Tx[K, V]) Delete(key K) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	if _, err := tx.Find(key); err != nil {
+		return err
+	}
+	tx.set(key, nil)
+	return nil
+}
+
+// Rollback :放弃事务中的所有写
+func (tx *Tx[K, V]) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	tx.writes = nil
+	return nil
+}
+
+// Commit :提交事务 有冲突时返回ErrConflict 事务中的写全部丢弃
+func (tx *Tx[K, V]) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+
+	t := tx.t
+	if t.concurrent {
+		t.cowLatch.Lock()
+		defer t.cowLatch.Unlock()
+	}
+
+	// 1.检查冲突 当前的Record必须和快照中的是同一个
+	current := make([]*Record[V], len(tx.writes))
+	for i, w := range tx.writes {
+		old, _ := tx.snap.Find(w.key)
+		if leaf := t.findLeaf(w.key); leaf != nil {
+			current[i] = leaf.getRecord(w.key, t.compare)
+		}
+		if current[i] != old {
+			return ErrConflict
+		}
+	}
+
+	// 2.写入tree 已经存在的key直接替换Record
+	for i, w := range tx.writes {
+		var err error
+		if w.record != nil {
+			err = t.insert(w.key, w.record, true)
+		} else if current[i] != nil {
+			err = t.delete(w.key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
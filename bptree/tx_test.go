@@ -0,0 +1,190 @@
+package bptree
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestTxReadYourWrites(t *testing.T) {
+	tree := NewTree[int, string](WithOrder(16))
+	for key := 0; key < 100; key++ {
+		_ = tree.Insert(key, "old")
+	}
+
+	tx := tree.Begin()
+	_ = tx.Delete(10)
+	_ = tx.Insert(10, "new")
+	_ = tx.Delete(20)
+	_ = tx.Insert(150, "new")
+	if err := tx.Insert(30, "dup"); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+	if err := tx.Delete(20); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+
+	if rec, err := tx.Find(10); err != nil || rec.Value != "new" {
+		t.Errorf("expected new value in transaction, %v", err)
+	}
+	if _, err := tx.Find(20); err == nil {
+		t.Errorf("expected deleted key to be invisible")
+	}
+	keys, records, _ := tx.FindRange(8, 200)
+	// 8~99中删除了20 新增了150
+	if len(keys) != 92 || keys[len(keys)-1] != 150 || records[2].Value != "new" {
+		t.Errorf("unexpected range %v", keys)
+	}
+
+	// 提交之前tree中看不到事务的写
+	if rec, _ := tree.Find(10); rec.Value != "old" {
+		t.Errorf("uncommitted write is visible")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if rec, _ := tree.Find(10); rec.Value != "new" {
+		t.Errorf("committed write is not visible")
+	}
+	if _, err := tree.Find(20); err == nil {
+		t.Errorf("expected key 20 to be deleted")
+	}
+	if _, err := tree.Find(150); err != nil {
+		t.Errorf("expected key 150 to be inserted")
+	}
+
+	if err := tx.Commit(); !errors.Is(err, ErrTxDone) {
+		t.Errorf("expected ErrTxDone and got %v", err)
+	}
+	if _, err := tx.Find(1); !errors.Is(err, ErrTxDone) {
+		t.Errorf("expected ErrTxDone and got %v", err)
+	}
+}
+
+func TestTxIsolationAndRollback(t *testing.T) {
+	tree := NewTree[int, string]()
+	for key := 0; key < 10; key++ {
+		_ = tree.Insert(key, "v")
+	}
+
+	tx := tree.Begin()
+	_ = tree.Insert(100, "v")
+	_ = tree.Delete(0)
+	keys, _, _ := tx.FindRange(0, 1000)
+	if !reflect.DeepEqual(keys, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) {
+		t.Errorf("transaction sees later writes: %v", keys)
+	}
+
+	_ = tx.Insert(50, "v")
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := tree.Find(50); err == nil {
+		t.Errorf("rolled back write is visible")
+	}
+	if err := tx.Insert(60, "v"); !errors.Is(err, ErrTxDone) {
+		t.Errorf("expected ErrTxDone and got %v", err)
+	}
+}
+
+// 两个事务修改同一个key 后提交的失败并且不写入任何数据
+func TestTxConflict(t *testing.T) {
+	tree := NewTree[int, string]()
+	_ = tree.Insert(1, "a")
+	_ = tree.Insert(2, "b")
+
+	tx1 := tree.Begin()
+	tx2 := tree.Begin()
+	_ = tx1.Delete(1)
+	_ = tx1.Insert(1, "tx1")
+	_ = tx2.Insert(3, "tx2")
+	_ = tx2.Delete(1)
+
+	if err := tx1.Commit(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := tx2.Commit(); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict and got %v", err)
+	}
+	if _, err := tree.Find(3); err == nil {
+		t.Errorf("write of conflicting transaction is visible")
+	}
+	if rec, _ := tree.Find(1); rec.Value != "tx1" {
+		t.Errorf("expected tx1 and got %s", rec.Value)
+	}
+
+	// 不使用事务的写同样会造成冲突 删除后重新插入也算修改
+	tx3 := tree.Begin()
+	_ = tx3.Delete(2)
+	_ = tree.Delete(2)
+	_ = tree.Insert(2, "b")
+	if err := tx3.Commit(); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict and got %v", err)
+	}
+
+	// 写不同的key没有冲突
+	tx4, tx5 := tree.Begin(), tree.Begin()
+	_ = tx4.Insert(4, "tx4")
+	_ = tx5.Insert(5, "tx5")
+	if tx4.Commit() != nil || tx5.Commit() != nil {
+		t.Errorf("expected both transactions to commit")
+	}
+}
+
+// 并发模式下用事务在key之间移动数据 任何时候读到的key数量都不变
+func TestTxConcurrentMove(t *testing.T) {
+	tree := NewTree[int, int](WithOrder(16), WithConcurrency())
+	const slots, items = 200, 50
+	for key := 0; key < items; key++ {
+		_ = tree.Insert(key*(slots/items), key)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := testRand(t)
+			for moved := 0; moved < 100; {
+				from, to := r.Intn(slots), r.Intn(slots)
+				tx := tree.Begin()
+				rec, err := tx.Find(from)
+				if err != nil || tx.Insert(to, rec.Value) != nil {
+					_ = tx.Rollback()
+					continue
+				}
+				_ = tx.Delete(from)
+				if err := tx.Commit(); err == nil {
+					moved++
+				} else if !errors.Is(err, ErrConflict) {
+					t.Errorf("%s", err)
+					return
+				}
+			}
+		}(w)
+	}
+	for w := 0; w < 2; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if keys, _ := tree.FindRange(-1, slots); len(keys) != items {
+					t.Errorf("expected %d keys and got %d", items, len(keys))
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	keys, records := tree.FindRange(-1, slots)
+	seen := make(map[int]bool)
+	for i := range keys {
+		seen[records[i].Value] = true
+	}
+	if len(seen) != items {
+		t.Errorf("expected %d distinct values and got %d", items, len(seen))
+	}
+	checkLinks(t, tree)
+}
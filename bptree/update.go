@@ -0,0 +1,124 @@
+package bptree
+
+import "slices"
+
+// *********************** 原地更新 ***********************
+/*
+Put/Update/CompareAndSwap(Func)只下降一次到key所在的叶子节点,不需要Delete之后再Insert(两次调整树结构):
+1. 替换value时总是创建新的Record替换叶子节点中的指针,不修改原来的Record。
+   快照和事务持有的Record不会被改变,事务提交时也用Record是否为同一个来检测冲突。
+2. 替换不会改变树的结构,并发模式下只需要锁住叶子节点;Put插入新key时与Insert相同。
+*/
+
+// Put :插入key key已经存在时替换value
+func (t *Tree[K, V]) Put(key K, value V) error {
+	pointer, err := newRecord(value)
+	if err != nil {
+		return err
+	}
+	if t.concurrent {
+		return t.concurrentInsert(key, pointer, true)
+	}
+	return t.insert(key, pointer, true)
+}
+
+// Update :用fn计算key的新value fn返回false时不修改 key不存在时返回错误
+/*
+并发模式下fn在持有叶子节点写latch时调用,同一个key的Update互相排斥,fn中不能调用这棵树的方法。
+*/
+func (t *Tree[K, V]) Update(key K, fn func(old V) (V, bool)) error {
+	return t.replace(key, func(old *Record[V]) *Record[V] {
+		value, ok := fn(old.Value)
+		if !ok {
+			return nil
+		}
+		r, _ := newRecord(value)
+		return r
+	})
+}
+
+// CompareAndSwap :key当前的Record是old(Find的返回值)时替换为value 返回是否替换
+/*
+按Record指针比较而不是按value比较:value相同的两个Record不相等,value不需要是可比较类型;
+在old之后key被修改过(包括Put相同的value、删除后重新插入)都会返回false。
+按value比较使用CompareAndSwapFunc或者CompareAndSwapValue。
+*/
+func (t *Tree[K, V]) CompareAndSwap(key K, old *Record[V], value V) bool {
+	return t.swap(key, func(current *Record[V]) bool { return current == old }, value)
+}
+
+// CompareAndSwapFunc :match(key当前的value)返回true时替换为value 返回是否替换
+/*
+比较和替换在一次下降中完成,并发模式下match在持有叶子节点写latch时调用,与Update相同。
+*/
+func (t *Tree[K, V]) CompareAndSwapFunc(key K, match func(old V) bool, value V) bool {
+	return t.swap(key, func(current *Record[V]) bool { return match(current.Value) }, value)
+}
+
+// CompareAndSwapValue :key当前的value等于old时替换为value 返回是否替换 V需要是可比较类型
+func CompareAndSwapValue[K any, V comparable](t *Tree[K, V], key K, old, value V) bool {
+	return t.CompareAndSwapFunc(key, func(current V) bool { return current == old }, value)
+}
+
+// match返回true时替换为value
+func (t *Tree[K, V]) swap(key K, match func(current *Record[V]) bool, value V) bool {
+	swapped := false
+	_ = t.replace(key, func(current *Record[V]) *Record[V] {
+		if !match(current) {
+			return nil
+		}
+		swapped = true
+		r, _ := newRecord(value)
+		return r
+	})
+	return swapped
+}
+
+// 用fn的返回值替换key的Record fn返回nil时不替换
+func (t *Tree[K, V]) replace(key K, fn func(old *Record[V]) *Record[V]) error {
+	if t.concurrent {
+		if err := t.latchedReplace(key, fn); err != errShared {
+			return err
+		}
+		// 叶子节点被快照共享 独占整棵树复制
+		t.cowLatch.Lock()
+		defer t.cowLatch.Unlock()
+	}
+
+	leaf := t.findLeaf(key)
+	i := -1
+	if leaf != nil {
+		i = leaf.getKeyIndex(key, t.compare)
+	}
+	if i == -1 {
+		return keyError(ErrKeyNotFound, key)
+	}
+	if r := fn(leaf.Pointers[i].(*Record[V])); r != nil {
+		t.writable(leaf).Pointers[i] = r
+	}
+	return nil
+}
+
+// 并发模式下锁住叶子节点替换 发现叶子节点被共享时在调用fn之前返回errShared
+func (t *Tree[K, V]) latchedReplace(key K, fn func(old *Record[V]) *Record[V]) error {
+	t.cowLatch.RLock()
+	defer t.cowLatch.RUnlock()
+
+	leaf, ancestors := t.lockPath(key)
+	if leaf == nil {
+		return keyError(ErrKeyNotFound, key)
+	}
+	defer unlockPath(leaf, ancestors)
+
+	i := leaf.getKeyIndex(key, t.compare)
+	if i == -1 {
+		return keyError(ErrKeyNotFound, key)
+	}
+	if t.shared(leaf) || slices.ContainsFunc(ancestors, t.shared) {
+		return errShared
+	}
+	if r := fn(leaf.Pointers[i].(*Record[V])); r != nil {
+		leaf.Pointers[i] = r
+	}
+	return nil
+}
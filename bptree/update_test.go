@@ -0,0 +1,155 @@
+package bptree
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestPutUpdate(t *testing.T) {
+	for _, opts := range [][]Option{{WithOrder(4)}, {WithOrder(4), WithConcurrency()}} {
+		tree := NewTree[int, string](opts...)
+		for key := 0; key < 100; key++ {
+			if err := tree.Put(key, "a"); err != nil {
+				t.Fatalf("%s", err)
+			}
+		}
+		old, _ := tree.Find(10)
+		snap := tree.Snapshot()
+		tx := tree.Begin()
+		_ = tx.Delete(10)
+
+		// 替换value不改变树的结构 也不修改原来的Record
+		if err := tree.Put(10, "b"); err != nil {
+			t.Fatalf("%s", err)
+		}
+		if rec, _ := tree.Find(10); rec.Value != "b" || old.Value != "a" {
+			t.Errorf("expected new record and got %s, old record %s", rec.Value, old.Value)
+		}
+		if rec, _ := snap.Find(10); rec != old {
+			t.Errorf("snapshot sees Put")
+		}
+		if err := tx.Commit(); !errors.Is(err, ErrConflict) {
+			t.Errorf("expected ErrConflict and got %v", err)
+		}
+		if n := tree.Len(); n != 100 {
+			t.Errorf("expected 100 keys and got %d", n)
+		}
+
+		// Update
+		if err := tree.Update(20, func(old string) (string, bool) { return old + "c", true }); err != nil {
+			t.Fatalf("%s", err)
+		}
+		if rec, _ := tree.Find(20); rec.Value != "ac" {
+			t.Errorf("expected ac and got %s", rec.Value)
+		}
+		before, _ := tree.Find(30)
+		_ = tree.Update(30, func(old string) (string, bool) { return "x", false })
+		if rec, _ := tree.Find(30); rec != before {
+			t.Errorf("expected unchanged record when fn returns false")
+		}
+		if err := tree.Update(1000, func(old string) (string, bool) { return old, true }); err == nil {
+			t.Errorf("expected error for missing key")
+		}
+
+		// CompareAndSwap
+		rec, _ := tree.Find(40)
+		if !tree.CompareAndSwap(40, rec, "d") {
+			t.Errorf("expected CompareAndSwap to succeed")
+		}
+		if tree.CompareAndSwap(40, rec, "e") || tree.CompareAndSwap(1000, nil, "e") {
+			t.Errorf("expected CompareAndSwap to fail")
+		}
+		if rec, _ := tree.Find(40); rec.Value != "d" {
+			t.Errorf("expected d and got %s", rec.Value)
+		}
+		// 按Record比较 Put相同的value之后旧的Record不再匹配
+		rec, _ = tree.Find(40)
+		_ = tree.Put(40, "d")
+		if tree.CompareAndSwap(40, rec, "e") {
+			t.Errorf("expected CompareAndSwap to fail after Put")
+		}
+
+		// 按value比较
+		if tree.CompareAndSwapFunc(40, func(old string) bool { return old == "x" }, "e") ||
+			CompareAndSwapValue(tree, 40, "x", "e") || CompareAndSwapValue(tree, 1000, "", "e") {
+			t.Errorf("expected CompareAndSwapFunc/CompareAndSwapValue to fail")
+		}
+		if !tree.CompareAndSwapFunc(40, func(old string) bool { return old == "d" }, "e") {
+			t.Errorf("expected CompareAndSwapFunc to succeed")
+		}
+		if !CompareAndSwapValue(tree, 40, "e", "f") {
+			t.Errorf("expected CompareAndSwapValue to succeed")
+		}
+		if rec, _ := tree.Find(40); rec.Value != "f" {
+			t.Errorf("expected f and got %s", rec.Value)
+		}
+		checkLinks(t, tree)
+		checkSizes(t, tree)
+	}
+}
+
+// 并发Update同一批key 每次递增都不会丢失
+func TestUpdateConcurrent(t *testing.T) {
+	tree := NewTree[int, int](WithOrder(8), WithConcurrency())
+	const keys, workers, rounds = 50, 8, 200
+	for key := 0; key < keys; key++ {
+		_ = tree.Put(key, 0)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				key := (w + i) % keys
+				if i%50 == 0 {
+					// 快照让叶子节点被共享 修改时复制
+					_ = tree.Snapshot()
+				}
+				if err := tree.Update(key, func(old int) (int, bool) { return old + 1, true }); err != nil {
+					t.Errorf("%s", err)
+					return
+				}
+				for {
+					rec, _ := tree.Find(key + keys)
+					if rec == nil {
+						if tree.Insert(key+keys, 1) == nil {
+							break
+						}
+						continue
+					}
+					if tree.CompareAndSwap(key+keys, rec, rec.Value+1) {
+						break
+					}
+				}
+				for {
+					v, ok := tree.Get(key + 2*keys)
+					if !ok {
+						if tree.Insert(key+2*keys, 1) == nil {
+							break
+						}
+						continue
+					}
+					if CompareAndSwapValue(tree, key+2*keys, v, v+1) {
+						break
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for _, base := range []int{0, keys, 2 * keys} {
+		sum := 0
+		for key := base; key < base+keys; key++ {
+			rec, _ := tree.Find(key)
+			sum += rec.Value
+		}
+		if sum != workers*rounds {
+			t.Errorf("expected sum %d and got %d", workers*rounds, sum)
+		}
+	}
+	checkSizes(t, tree)
+}
@@ -0,0 +1,137 @@
+package bptree
+
+import "fmt"
+
+// *********************** 结构检查 ***********************
+/*
+Validate遍历整棵树检查B+树的约束,发现问题时返回包装ErrCorrupt的错误:
+1. 每个节点Count == len(Keys),Keys严格递增,非根节点的key数量在minLimit和maxLimit之间。
+2. 非叶子节点len(Pointers) == len(Keys)+1,子节点的Parent指向自己,子树中的key都在分隔key之间:
+   第i个子树中的key满足 Keys[i-1] <= key < Keys[i]。
+3. 叶子节点len(Pointers) == len(Keys)且都指向Record,所有叶子节点在同一层,
+   Next/Prev链表按顺序串起所有叶子节点。
+4. 每个节点的size等于子树中的数据数量(见rank.go)。
+用于测试以及调试时尽早发现错误的修改,检查需要遍历所有节点,不适合频繁调用。
+*/
+
+// Validate :检查整棵树的结构 返回发现的第一个问题 并发模式下检查期间独占整棵树
+func (t *Tree[K, V]) Validate() error {
+	if t.concurrent {
+		t.cowLatch.Lock()
+		defer t.cowLatch.Unlock()
+	}
+	if t.Root == nil {
+		return nil
+	}
+	if t.Root.Parent != nil {
+		return invalid(t.Root, "root has a parent")
+	}
+
+	v := &validator[K, V]{t: t, leafDepth: -1}
+	if _, err := v.node(t.Root, 0, nil, nil); err != nil {
+		return err
+	}
+	return v.chain()
+}
+
+// 遍历时的状态 leaves按从左到右的顺序记录所有叶子节点
+type validator[K any, V any] struct {
+	t         *Tree[K, V]
+	leafDepth int
+	leaves    []*Node[K, V]
+}
+
+// 检查以n为根的子树 子树中的key必须在[lo, hi)之间(nil表示不限制) 返回子树中的数据数量
+func (v *validator[K, V]) node(n *Node[K, V], depth int, lo, hi *K) (int64, error) {
+	t := v.t
+	if n.Count != len(n.Keys) {
+		return 0, invalid(n, fmt.Sprintf("Count is %d", n.Count))
+	}
+	if n != t.Root && (n.Count < t.minLimit || n.Count > t.maxLimit) {
+		return 0, invalid(n, fmt.Sprintf("%d keys out of [%d, %d]", n.Count, t.minLimit, t.maxLimit))
+	}
+	if n == t.Root && (n.Count == 0 || n.Count > t.maxLimit) {
+		return 0, invalid(n, fmt.Sprintf("root has %d keys", n.Count))
+	}
+	for i, key := range n.Keys {
+		if i > 0 && t.compare(n.Keys[i-1], key) >= 0 {
+			return 0, invalid(n, "keys are not in order")
+		}
+		if (lo != nil && t.compare(key, *lo) < 0) || (hi != nil && t.compare(key, *hi) >= 0) {
+			return 0, invalid(n, fmt.Sprintf("key %v out of parent separators", key))
+		}
+	}
+
+	var size int64
+	if n.IsLeaf {
+		if len(n.Pointers) != len(n.Keys) {
+			return 0, invalid(n, fmt.Sprintf("leaf has %d pointers", len(n.Pointers)))
+		}
+		for _, p := range n.Pointers {
+			if r, _ := p.(*Record[V]); r == nil {
+				return 0, invalid(n, "leaf pointer is not a record")
+			}
+		}
+		if v.leafDepth == -1 {
+			v.leafDepth = depth
+		} else if depth != v.leafDepth {
+			return 0, invalid(n, fmt.Sprintf("leaf at depth %d, expected %d", depth, v.leafDepth))
+		}
+		v.leaves = append(v.leaves, n)
+		size = int64(n.Count)
+	} else {
+		if len(n.Pointers) != len(n.Keys)+1 {
+			return 0, invalid(n, fmt.Sprintf("internal node has %d pointers", len(n.Pointers)))
+		}
+		for i, p := range n.Pointers {
+			child, _ := p.(*Node[K, V])
+			if child == nil {
+				return 0, invalid(n, "internal pointer is not a node")
+			}
+			if child.Parent != n {
+				return 0, invalid(child, "broken Parent pointer")
+			}
+			childLo, childHi := lo, hi
+			if i > 0 {
+				childLo = &n.Keys[i-1]
+			}
+			if i < n.Count {
+				childHi = &n.Keys[i]
+			}
+			childSize, err := v.node(child, depth+1, childLo, childHi)
+			if err != nil {
+				return 0, err
+			}
+			size += childSize
+		}
+	}
+
+	if n.subtreeSize() != size {
+		return 0, invalid(n, fmt.Sprintf("size is %d, expected %d", n.subtreeSize(), size))
+	}
+	return size, nil
+}
+
+// 叶子链表和遍历得到的叶子顺序一致
+func (v *validator[K, V]) chain() error {
+	for i, leaf := range v.leaves {
+		var prev, next *Node[K, V]
+		if i > 0 {
+			prev = v.leaves[i-1]
+		}
+		if i < len(v.leaves)-1 {
+			next = v.leaves[i+1]
+		}
+		if leaf.Prev != prev {
+			return invalid(leaf, "broken Prev pointer")
+		}
+		if leaf.Next != next {
+			return invalid(leaf, "broken Next pointer")
+		}
+	}
+	return nil
+}
+
+func invalid[K any, V any](n *Node[K, V], problem string) error {
+	return fmt.Errorf("%w: node %v: %s", ErrCorrupt, n.Keys, problem)
+}
@@ -0,0 +1,80 @@
+package bptree
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, opts := range [][]Option{{WithOrder(4)}, {WithOrder(5), WithConcurrency()}, {WithOrder(64)}} {
+		tree := NewTree[int, int](opts...)
+		if err := tree.Validate(); err != nil {
+			t.Fatalf("empty tree: %s", err)
+		}
+		r := testRand(t)
+		for i := 0; i < 2000; i++ {
+			_ = tree.Insert(r.Intn(3000), i)
+			if i%500 == 0 {
+				_ = tree.Snapshot()
+			}
+		}
+		if err := tree.Validate(); err != nil {
+			t.Fatalf("order %d: %s", tree.order, err)
+		}
+		for i := 0; i < 2000; i++ {
+			_ = tree.Delete(r.Intn(3000))
+		}
+		if err := tree.Validate(); err != nil {
+			t.Fatalf("order %d after deletes: %s", tree.order, err)
+		}
+	}
+
+	tree := NewTree[int, int](WithOrder(6))
+	_ = tree.BulkLoad(sortedSeq(1000), 0.5)
+	if err := tree.Validate(); err != nil {
+		t.Fatalf("bulk load: %s", err)
+	}
+}
+
+// 每种被破坏的约束都能被发现
+func TestValidateCorruption(t *testing.T) {
+	cases := []struct {
+		name    string
+		corrupt func(tree *Tree[int, int])
+	}{
+		{"count", func(tree *Tree[int, int]) { tree.edgeLeaf(false).Count++ }},
+		{"order", func(tree *Tree[int, int]) {
+			leaf := tree.edgeLeaf(false)
+			leaf.Keys[0], leaf.Keys[1] = leaf.Keys[1], leaf.Keys[0]
+		}},
+		{"occupancy", func(tree *Tree[int, int]) {
+			leaf := tree.edgeLeaf(false)
+			leaf.Keys, leaf.Pointers, leaf.Count = leaf.Keys[:1], leaf.Pointers[:1], 1
+		}},
+		{"pointers", func(tree *Tree[int, int]) {
+			tree.Root.Pointers = tree.Root.Pointers[:tree.Root.Count]
+		}},
+		{"record", func(tree *Tree[int, int]) { tree.edgeLeaf(true).Pointers[0] = nil }},
+		{"parent", func(tree *Tree[int, int]) { tree.edgeLeaf(false).Parent = tree.Root }},
+		{"separator", func(tree *Tree[int, int]) { tree.Root.Keys[0] = -1 }},
+		{"depth", func(tree *Tree[int, int]) {
+			// 把一个叶子节点挂到根节点下面 其他叶子节点更深
+			leaf := tree.edgeLeaf(false)
+			tree.Root.Pointers[0] = leaf
+			leaf.Parent = tree.Root
+		}},
+		{"next", func(tree *Tree[int, int]) { tree.edgeLeaf(false).Next = nil }},
+		{"prev", func(tree *Tree[int, int]) { tree.edgeLeaf(true).Prev = nil }},
+		{"size", func(tree *Tree[int, int]) { tree.Root.size++ }},
+	}
+	for _, c := range cases {
+		tree := NewTree[int, int](WithOrder(4))
+		for key := 0; key < 200; key++ {
+			_ = tree.Insert(key, key)
+		}
+		c.corrupt(tree)
+		if err := tree.Validate(); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: expected ErrCorrupt and got %v", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package bptree
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// *********************** WAL ***********************
+/*
+DiskTree的每次Insert/Delete在WAL(数据文件路径加上"-wal"后缀)中追加一条记录,
+记录中是这次操作修改过的所有page的完整内容以及修改之后的元数据(物理redo日志):
+[0:4]crc32 [4:8]body长度 body: [0:8]root [8:16]pageCount [16:24]freeHead [24:28]page数量 然后依次是 8字节PageID + page内容
+
+1. 记录只追加到文件,Sync时刷盘。dirty page写回数据文件之前WAL一定已经刷盘。
+2. Checkpoint把所有dirty page和元数据写入数据文件并刷盘,然后清空WAL。
+3. Open时按顺序重放WAL中完整的记录(page内容覆盖写入,重复重放也没有问题),
+   遇到不完整或者校验失败的记录说明是崩溃时写了一半的尾部,直接丢弃。
+*/
+
+const walHeaderSize = 8
+
+type wal struct {
+	file     *os.File
+	pageSize int
+	size     int64  // 已经写入的字节数
+	synced   bool   // 写入的记录是否都已经刷盘
+	buf      []byte // 记录编码缓冲区
+}
+
+func openWAL(path string, pageSize int) (*wal, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &wal{file: file, pageSize: pageSize, synced: true}, nil
+}
+
+// 追加一条记录
+func (w *wal) append(m meta, frames []*frame) error {
+	buf := append(w.buf[:0], make([]byte, walHeaderSize)...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(m.root))
+	buf = binary.BigEndian.AppendUint64(buf, m.pageCount)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(m.freeHead))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(frames)))
+	for _, f := range frames {
+		buf = binary.BigEndian.AppendUint64(buf, uint64(f.id))
+		buf = append(buf, f.data...)
+	}
+	binary.BigEndian.PutUint32(buf[4:8], uint32(len(buf)-walHeaderSize))
+	binary.BigEndian.PutUint32(buf[0:4], crc32.ChecksumIEEE(buf[4:]))
+	w.buf = buf
+
+	if _, err := w.file.WriteAt(buf, w.size); err != nil {
+		return err
+	}
+	w.size += int64(len(buf))
+	w.synced = false
+	return nil
+}
+
+func (w *wal) sync() error {
+	if w.synced {
+		return nil
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.synced = true
+	return nil
+}
+
+// 清空WAL 只能在数据文件刷盘之后调用
+func (w *wal) truncate() error {
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	w.size = 0
+	w.synced = false
+	return w.sync()
+}
+
+// 把WAL中完整的记录重放到数据文件 然后清空WAL 返回重放的记录数量
+func (w *wal) replay(p *Pager) (int, error) {
+	data, err := io.ReadAll(io.NewSectionReader(w.file, 0, 1<<62))
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for len(data) >= walHeaderSize {
+		size := binary.BigEndian.Uint32(data[4:8])
+		if uint64(len(data)-walHeaderSize) < uint64(size) {
+			break
+		}
+		record := data[:walHeaderSize+int(size)]
+		if binary.BigEndian.Uint32(record[0:4]) != crc32.ChecksumIEEE(record[4:]) {
+			break
+		}
+
+		body := record[walHeaderSize:]
+		if len(body) < 28 {
+			break
+		}
+		pages := int(binary.BigEndian.Uint32(body[24:28]))
+		if len(body) != 28+pages*(8+w.pageSize) {
+			break
+		}
+
+		p.meta.root = PageID(binary.BigEndian.Uint64(body[0:8]))
+		p.meta.pageCount = binary.BigEndian.Uint64(body[8:16])
+		p.meta.freeHead = PageID(binary.BigEndian.Uint64(body[16:24]))
+		for page := body[28:]; len(page) > 0; page = page[8+w.pageSize:] {
+			id := PageID(binary.BigEndian.Uint64(page[0:8]))
+			if err := p.WritePage(id, page[8:8+w.pageSize]); err != nil {
+				return count, err
+			}
+		}
+
+		count++
+		data = data[walHeaderSize+int(size):]
+	}
+
+	if count > 0 {
+		if err := p.Sync(); err != nil {
+			return count, err
+		}
+	}
+	return count, w.truncate()
+}
+
+func (w *wal) close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,159 @@
+package bptree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// 模拟崩溃:直接关闭文件 缓存中的dirty page和元数据都不写入数据文件
+func crashDiskTree[K any, V any](tree *DiskTree[K, V]) {
+	tree.log.close()
+	tree.pager.file.Close()
+}
+
+// 把崩溃时的数据文件和截断到size的WAL复制到新目录
+func copyCrashFiles(t *testing.T, data, log []byte, size int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tree.db")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := os.WriteFile(path+"-wal", log[:size], 0644); err != nil {
+		t.Fatalf("%s", err)
+	}
+	return path
+}
+
+func sortedKeys(model map[int]string) []int {
+	keys := make([]int, 0, len(model))
+	for key := range model {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// WAL在任意位置被截断 恢复后的数据正好是最后一条完整记录之后的状态
+func TestWALTruncatedRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.db")
+	tree := openTestDiskTree(t, path, WithOrder(8), WithPageSize(512))
+	model := make(map[int]string)
+	for key := 0; key < 300; key++ {
+		_ = tree.Insert(key, fmt.Sprint(key))
+		model[key] = fmt.Sprint(key)
+	}
+	if err := tree.Checkpoint(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// 每条记录结束的位置 以及对应的数据
+	ends := []int{0}
+	states := [][]int{sortedKeys(model)}
+	r := testRand(t)
+	for i := 0; i < 200; i++ {
+		key := r.Intn(600)
+		if r.Intn(2) == 0 {
+			if tree.Insert(key, fmt.Sprint(key)) == nil {
+				model[key] = fmt.Sprint(key)
+			}
+		} else if tree.Delete(key) == nil {
+			delete(model, key)
+		}
+		if size := int(tree.log.size); size != ends[len(ends)-1] {
+			ends = append(ends, size)
+			states = append(states, sortedKeys(model))
+		}
+	}
+	if tree.PoolStats().Evictions != 0 {
+		t.Fatalf("expected no evictions")
+	}
+	crashDiskTree(tree)
+
+	data, _ := os.ReadFile(path)
+	log, _ := os.ReadFile(path + "-wal")
+	if len(log) != ends[len(ends)-1] {
+		t.Fatalf("expected wal size %d and got %d", ends[len(ends)-1], len(log))
+	}
+
+	offsets := []int{0, 1, len(log) - 1, len(log)}
+	for i := 0; i < 40; i++ {
+		offsets = append(offsets, r.Intn(len(log)+1))
+	}
+	for _, offset := range offsets {
+		k, _ := slices.BinarySearch(ends, offset+1)
+		want := states[k-1]
+
+		tree := openTestDiskTree(t, copyCrashFiles(t, data, log, offset))
+		keys, _, err := tree.FindRange(-1, 1<<30)
+		if err != nil {
+			t.Fatalf("offset %d: %s", offset, err)
+		}
+		if !reflect.DeepEqual(keys, want) {
+			t.Fatalf("offset %d: expected %d keys and got %d", offset, len(want), len(keys))
+		}
+		// 恢复之后可以继续写入
+		if err := tree.Insert(1000, "1000"); err != nil {
+			t.Fatalf("offset %d: %s", offset, err)
+		}
+		tree.Close()
+	}
+}
+
+// 缓存很小时dirty page会在崩溃前写回数据文件 WAL中的记录仍然可以恢复完整的数据
+func TestWALRecoveryWithEviction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.db")
+	tree := openTestDiskTree(t, path, WithOrder(4), WithPageSize(512), WithPoolSize(8))
+	model := make(map[int]string)
+	r := testRand(t)
+	for i := 0; i < 3000; i++ {
+		key := r.Intn(1000)
+		if r.Intn(3) > 0 {
+			if tree.Insert(key, fmt.Sprint(key)) == nil {
+				model[key] = fmt.Sprint(key)
+			}
+		} else if tree.Delete(key) == nil {
+			delete(model, key)
+		}
+		if i == 1000 {
+			_ = tree.Checkpoint()
+		}
+	}
+	if err := tree.Sync(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if tree.PoolStats().Evictions == 0 {
+		t.Fatalf("expected evictions with a small pool")
+	}
+	crashDiskTree(tree)
+
+	// 尾部追加半条记录 模拟写到一半时崩溃
+	data, _ := os.ReadFile(path)
+	log, _ := os.ReadFile(path + "-wal")
+	log = append(log, 0, 0, 0, 0, 0, 0, 1, 0, 7, 7)
+
+	tree = openTestDiskTree(t, copyCrashFiles(t, data, log, len(log)))
+	defer tree.Close()
+	checkDiskTree(t, tree, model)
+}
+
+// 正常关闭之后WAL为空
+func TestWALCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.db")
+	tree := openTestDiskTree(t, path)
+	for key := 0; key < 100; key++ {
+		_ = tree.Insert(key, fmt.Sprint(key))
+	}
+	if tree.log.size == 0 {
+		t.Errorf("expected wal records before checkpoint")
+	}
+	if err := tree.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if info, err := os.Stat(path + "-wal"); err != nil || info.Size() != 0 {
+		t.Errorf("expected empty wal after close, %v", err)
+	}
+}
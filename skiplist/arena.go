@@ -0,0 +1,427 @@
+package skiplist
+
+import (
+	"bytes"
+	"errors"
+	"iter"
+	"math"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// *********************** arena跳表 ***********************
+/*
+ArenaSkipList把节点、key和value都复制到一块预先分配的[]byte中,用offset代替指针链接,
+整个跳表只有一次Go内存分配,arena中没有指针,GC不需要扫描:
+1. 分配只是原子地移动arena的末尾,空间不会回收:替换value时新value写到arena末尾,删除的节点也不回收。
+   Size()是arena已经使用的字节数,就是跳表实际占用的内存;剩余空间不够时Put返回ErrArenaFull,
+   memtable写满后调用Freeze冻结,之后只能读取,Freeze返回的整块arena直接交给flush。
+2. 并发算法与ConcurrentSkipList相同,节点都按8字节对齐,offset的最低位用作标记,
+   一个uint32就能原子地替换link{node, marked};value的offset和长度合在一个uint64中原子地替换,0表示已经删除。
+3. 节点只分配height层的next,高层不占用arena空间。
+4. 节点从不复用,offset不会指向另一个节点,没有ABA问题。
+返回的key和value直接引用arena,调用者不能修改。
+*/
+
+// ErrArenaFull :arena剩余空间不够
+var ErrArenaFull = errors.New("skiplist: arena is full")
+
+// ErrFrozen :跳表已经冻结 不能再修改
+var ErrFrozen = errors.New("skiplist: arena skiplist is frozen")
+
+type ArenaSkipList struct {
+	arena   *arena
+	head    uint32
+	length  atomic.Int64          // key数量
+	compare func(a, b []byte) int // key比较函数 默认bytes.Compare
+	levels  *levelGenerator       // 生成随机层级
+	height  int                   // 头节点的层数 即最大层级
+	writing sync.RWMutex          // Put/Delete持有读锁 Freeze持有写锁 等待正在进行的写入完成
+	frozen  bool                  // Freeze之后Put/Delete失败 由writing保护
+}
+
+// arena中的节点 key紧跟在tower之后
+type arenaNode struct {
+	value     atomic.Uint64 // value的offset<<32|长度 0表示已经删除
+	keyOffset uint32
+	keySize   uint32
+	height    uint32
+	// 每一层下一个节点的offset 最低位为1表示这个节点在这一层已经被删除 只分配height层
+	tower [maxLevelLimit]atomic.Uint32
+}
+
+const (
+	nodeAlign   = 8
+	maxNodeSize = uint32(unsafe.Sizeof(arenaNode{}))
+	marked      = 1
+)
+
+// 只有height层tower的节点大小
+func nodeSize(height int) uint32 {
+	return maxNodeSize - uint32(maxLevelLimit-height)*uint32(unsafe.Sizeof(atomic.Uint32{}))
+}
+
+// NewArenaSkipList :创建容量为capacity字节的跳表 容量包括头节点 最大4GB key按bytes.Compare比较
+func NewArenaSkipList(capacity int, opts ...Option) *ArenaSkipList {
+	return NewArenaSkipListFunc(capacity, bytes.Compare, opts...)
+}
+
+// NewArenaSkipListFunc :创建容量为capacity字节的跳表 使用自定义的key比较函数
+func NewArenaSkipListFunc(capacity int, compare func(a, b []byte) int, opts ...Option) *ArenaSkipList {
+	capacity = min(max(capacity, int(nodeAlign+maxNodeSize)), math.MaxUint32)
+	c := newConfig(opts)
+	s := &ArenaSkipList{
+		arena:   newArena(uint32(capacity)),
+		compare: compare,
+		levels:  newLevelGenerator(c),
+		height:  c.maxLevel,
+	}
+	s.head, _ = s.arena.allocate(nodeSize(c.maxLevel), nodeAlign)
+	s.node(s.head).height = uint32(c.maxLevel)
+	return s
+}
+
+// Put :插入key key已经存在时替换value arena空间不够时返回ErrArenaFull 冻结之后返回ErrFrozen
+func (s *ArenaSkipList) Put(key, value []byte) error {
+	s.writing.RLock()
+	defer s.writing.RUnlock()
+	if s.frozen {
+		return ErrFrozen
+	}
+	var preds, succs [maxLevelLimit]uint32
+	var off uint32  // 已经分配但还没有链接的新节点
+	var valv uint64 // 已经写入arena的value
+	for {
+		if s.find(key, &preds, &succs) {
+			// 1.key已经存在 替换value 节点正在被删除时帮助标记后重试
+			node := s.node(succs[0])
+			old := node.value.Load()
+			if old == 0 {
+				node.mark()
+				continue
+			}
+			if valv == 0 {
+				valOff, ok := s.arena.allocate(uint32(len(value)), 1)
+				if !ok {
+					return ErrArenaFull
+				}
+				valv = s.arena.put(valOff, value)
+			}
+			if node.value.CompareAndSwap(old, valv) {
+				return nil
+			}
+			continue
+		}
+
+		// 2.分配新节点 key和value(还没有写入时)紧跟在节点之后 重试时复用
+		if off == 0 {
+			height := s.levels.level()
+			size := nodeSize(height) + uint32(len(key))
+			if valv == 0 {
+				size += uint32(len(value))
+			}
+			var ok bool
+			if off, ok = s.arena.allocate(size, nodeAlign); !ok {
+				return ErrArenaFull
+			}
+			node := s.node(off)
+			node.keyOffset, node.keySize, node.height = off+nodeSize(height), uint32(len(key)), uint32(height)
+			s.arena.put(node.keyOffset, key)
+			if valv == 0 {
+				valv = s.arena.put(node.keyOffset+node.keySize, value)
+			}
+			node.value.Store(valv)
+		}
+
+		// 3.链接最底层 失败说明前后节点有变化 重新查找
+		node := s.node(off)
+		for i := 0; i < int(node.height); i++ {
+			node.tower[i].Store(succs[i])
+		}
+		if !s.node(preds[0]).tower[0].CompareAndSwap(succs[0], off) {
+			continue
+		}
+		s.length.Add(1)
+
+		// 4.逐层链接高层
+		s.linkLevels(off, &preds, &succs)
+		return nil
+	}
+}
+
+// 把已经链接最底层的节点链接到高层 节点被删除时停止
+func (s *ArenaSkipList) linkLevels(off uint32, preds, succs *[maxLevelLimit]uint32) {
+	node := s.node(off)
+	key := s.key(node)
+	for i := 1; i < int(node.height); i++ {
+		for {
+			next := node.tower[i].Load()
+			if next&marked != 0 {
+				return
+			}
+			if next != succs[i] && !node.tower[i].CompareAndSwap(next, succs[i]) {
+				continue
+			}
+			if s.node(preds[i]).tower[i].CompareAndSwap(succs[i], off) {
+				break
+			}
+			if !s.find(key, preds, succs) || succs[0] != off {
+				return
+			}
+		}
+	}
+}
+
+// Get :查找key 不存在时返回nil
+func (s *ArenaSkipList) Get(key []byte) []byte {
+	node := s.node(s.findGreaterOrEqual(key, true))
+	if node == nil || s.compare(s.key(node), key) != 0 {
+		return nil
+	}
+	if valv := node.value.Load(); valv != 0 {
+		return s.arena.get(valv)
+	}
+	return nil
+}
+
+// Delete :删除key 返回key是否存在 节点占用的空间不回收 冻结之后不删除 返回false
+func (s *ArenaSkipList) Delete(key []byte) bool {
+	s.writing.RLock()
+	defer s.writing.RUnlock()
+	if s.frozen {
+		return false
+	}
+	var preds, succs [maxLevelLimit]uint32
+	for {
+		if !s.find(key, &preds, &succs) {
+			return false
+		}
+		node := s.node(succs[0])
+		old := node.value.Load()
+		if old == 0 {
+			// 其他goroutine已经删除
+			node.mark()
+			return false
+		}
+		if !node.value.CompareAndSwap(old, 0) {
+			// value被Put替换 重试
+			continue
+		}
+
+		s.length.Add(-1)
+		node.mark()
+		s.find(key, &preds, &succs)
+		return true
+	}
+}
+
+// All :按key从小到大遍历 可以直接用于for range 遍历期间可以并发修改
+func (s *ArenaSkipList) All() iter.Seq2[[]byte, []byte] {
+	return s.scan(nil, nil, false, false)
+}
+
+// Backward :按key从大到小遍历
+func (s *ArenaSkipList) Backward() iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		for p := s.node(s.findLess(nil, false)); p != nil; p = s.node(s.findLess(s.key(p), true)) {
+			if valv := p.value.Load(); valv != 0 && !yield(s.key(p), s.arena.get(valv)) {
+				return
+			}
+		}
+	}
+}
+
+// Range :按key从小到大遍历lo<=key<hi的数据 nil和其他key一样比较
+func (s *ArenaSkipList) Range(lo, hi []byte) iter.Seq2[[]byte, []byte] {
+	return s.scan(lo, hi, true, true)
+}
+
+// RangeFrom :按key从小到大遍历key>=lo的数据 不限制上界
+func (s *ArenaSkipList) RangeFrom(lo []byte) iter.Seq2[[]byte, []byte] {
+	return s.scan(lo, nil, true, false)
+}
+
+// 遍历lo<=key<hi的数据 hasLo/hasHi为false时不限制下界/上界
+func (s *ArenaSkipList) scan(lo, hi []byte, hasLo, hasHi bool) iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		for p := s.node(s.findGreaterOrEqual(lo, hasLo)); p != nil; p = s.node(p.tower[0].Load() &^ marked) {
+			key := s.key(p)
+			if hasHi && s.compare(key, hi) >= 0 {
+				return
+			}
+			if p.tower[0].Load()&marked != 0 {
+				continue
+			}
+			if valv := p.value.Load(); valv != 0 && !yield(key, s.arena.get(valv)) {
+				return
+			}
+		}
+	}
+}
+
+// Freeze :冻结跳表 返回arena中已经使用的部分 长度等于Size()
+/*
+冻结之后Put返回ErrFrozen,Delete返回false,Get和遍历不受影响,返回的key和value仍然引用这块arena。
+Freeze等待已经开始的Put/Delete完成之后才返回,返回的[]byte之后不会再改变。
+写入之间只共享读锁,不互相等待。返回的[]byte和跳表共享内存,调用者不能修改。
+*/
+func (s *ArenaSkipList) Freeze() []byte {
+	s.writing.Lock()
+	defer s.writing.Unlock()
+	s.frozen = true
+	n := s.arena.len()
+	return s.arena.buf[:n:n]
+}
+
+// Size :arena已经使用的字节数 包括被替换的value和删除的节点
+func (s *ArenaSkipList) Size() int {
+	return int(s.arena.len())
+}
+
+func (s *ArenaSkipList) Length() int {
+	return int(s.length.Load())
+}
+
+// 与ConcurrentSkipList.find相同 经过已经标记的节点时把它从这一层摘除
+func (s *ArenaSkipList) find(key []byte, preds, succs *[maxLevelLimit]uint32) bool {
+retry:
+	pred := s.head
+	for i := s.height - 1; i >= 0; i-- {
+		curr := s.node(pred).tower[i].Load()
+		if curr&marked != 0 {
+			goto retry
+		}
+		for curr != 0 {
+			node := s.node(curr)
+			next := node.tower[i].Load()
+			if next&marked != 0 {
+				if !s.node(pred).tower[i].CompareAndSwap(curr, next&^marked) {
+					goto retry
+				}
+				curr = next &^ marked
+				continue
+			}
+			if s.compare(s.key(node), key) >= 0 {
+				break
+			}
+			pred, curr = curr, next
+		}
+		preds[i], succs[i] = pred, curr
+	}
+	return succs[0] != 0 && s.compare(s.key(s.node(succs[0])), key) == 0
+}
+
+// 第一个key>=key的未删除节点 bounded为false时返回第一个节点 只读
+func (s *ArenaSkipList) findGreaterOrEqual(key []byte, bounded bool) uint32 {
+	pred := s.head
+	var curr uint32
+	for i := s.height - 1; i >= 0; i-- {
+		curr = s.node(pred).tower[i].Load() &^ marked
+		for curr != 0 {
+			next := s.node(curr).tower[i].Load()
+			if next&marked != 0 {
+				curr = next &^ marked
+				continue
+			}
+			if !bounded || s.compare(s.key(s.node(curr)), key) >= 0 {
+				break
+			}
+			pred, curr = curr, next
+		}
+	}
+	return curr
+}
+
+// 最后一个key<key的未删除节点 bounded为false时返回最后一个节点 只读
+func (s *ArenaSkipList) findLess(key []byte, bounded bool) uint32 {
+	pred := s.head
+	for i := s.height - 1; i >= 0; i-- {
+		curr := s.node(pred).tower[i].Load() &^ marked
+		for curr != 0 {
+			next := s.node(curr).tower[i].Load()
+			if next&marked != 0 {
+				curr = next &^ marked
+				continue
+			}
+			if bounded && s.compare(s.key(s.node(curr)), key) >= 0 {
+				break
+			}
+			pred, curr = curr, next
+		}
+	}
+	if pred == s.head {
+		return 0
+	}
+	return pred
+}
+
+// offset处的节点 0返回nil
+func (s *ArenaSkipList) node(off uint32) *arenaNode {
+	if off == 0 {
+		return nil
+	}
+	return (*arenaNode)(unsafe.Pointer(&s.arena.buf[off]))
+}
+
+func (s *ArenaSkipList) key(n *arenaNode) []byte {
+	return s.arena.buf[n.keyOffset : n.keyOffset+n.keySize : n.keyOffset+n.keySize]
+}
+
+// 从高层到低层标记每一层 已经标记的层不变 可以由多个goroutine同时调用
+func (n *arenaNode) mark() {
+	for i := int(n.height) - 1; i >= 0; i-- {
+		for {
+			next := n.tower[i].Load()
+			if next&marked != 0 || n.tower[i].CompareAndSwap(next, next|marked) {
+				break
+			}
+		}
+	}
+}
+
+// *********************** arena ***********************
+
+// 只能追加分配的内存 offset 0不分配 用作nil
+type arena struct {
+	n   atomic.Uint32 // 已经使用的字节数
+	cap uint32
+	buf []byte
+}
+
+func newArena(capacity uint32) *arena {
+	// 末尾多留一个完整节点的空间 只分配部分tower的节点转换为*arenaNode时不会越过buf
+	a := &arena{cap: capacity, buf: make([]byte, uint64(capacity)+uint64(maxNodeSize))}
+	a.n.Store(1)
+	return a
+}
+
+// 分配size字节 起始位置按align对齐 空间不够时返回false
+func (a *arena) allocate(size, align uint32) (uint32, bool) {
+	for {
+		n := a.n.Load()
+		off := (uint64(n) + uint64(align) - 1) &^ (uint64(align) - 1)
+		end := off + uint64(size)
+		if end > uint64(a.cap) {
+			return 0, false
+		}
+		if a.n.CompareAndSwap(n, uint32(end)) {
+			return uint32(off), true
+		}
+	}
+}
+
+func (a *arena) len() uint32 {
+	return a.n.Load()
+}
+
+// 把value复制到off处 返回value的位置
+func (a *arena) put(off uint32, value []byte) uint64 {
+	copy(a.buf[off:], value)
+	return uint64(off)<<32 | uint64(len(value))
+}
+
+func (a *arena) get(valv uint64) []byte {
+	off, size := uint32(valv>>32), uint32(valv)
+	return a.buf[off : off+size : off+size]
+}
@@ -0,0 +1,150 @@
+package skiplist
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// key和value都复制到arena 调用者之后修改自己的slice不影响跳表
+func TestArenaSkipList_Copy(t *testing.T) {
+	s := NewArenaSkipList(1 << 10)
+	key, value := []byte("key"), []byte("value")
+	if err := s.Put(key, value); err != nil {
+		t.Fatalf("Put: %s", err)
+	}
+	key[0], value[0] = 'x', 'x'
+	if got := s.Get([]byte("key")); string(got) != "value" {
+		t.Errorf("Get() = %q, want %q", got, "value")
+	}
+	if got := s.Get(key); got != nil {
+		t.Errorf("Get(%q) = %q, want nil", key, got)
+	}
+}
+
+// 写满之后返回ErrArenaFull 已经写入的数据不受影响 Size是arena使用的字节数
+func TestArenaSkipList_Full(t *testing.T) {
+	const capacity = 1 << 14
+	s := NewArenaSkipList(capacity)
+	empty := s.Size()
+	value := make([]byte, 16)
+
+	n := 0
+	for ; ; n++ {
+		err := s.Put(concurrentKey(n), value)
+		if errors.Is(err, ErrArenaFull) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Put(%d): %s", n, err)
+		}
+		if s.Size() > capacity {
+			t.Fatalf("Size() = %d exceeds capacity %d", s.Size(), capacity)
+		}
+	}
+	if n == 0 || s.Length() != n {
+		t.Fatalf("Length() = %d, want %d", s.Length(), n)
+	}
+	// 每个节点至少包括key value和最底层的tower
+	if least := empty + n*(4+16+int(nodeSize(1))); s.Size() < least {
+		t.Errorf("Size() = %d, want at least %d", s.Size(), least)
+	}
+
+	// 写满之后失败的Put不占用空间 仍然可以查找和删除
+	size := s.Size()
+	if err := s.Put(concurrentKey(0), make([]byte, capacity)); !errors.Is(err, ErrArenaFull) {
+		t.Errorf("replacing with a large value: got %v, want ErrArenaFull", err)
+	}
+	if s.Size() != size {
+		t.Errorf("failed Put changed Size() from %d to %d", size, s.Size())
+	}
+	for i := 0; i < n; i++ {
+		if !bytes.Equal(s.Get(concurrentKey(i)), value) {
+			t.Fatalf("Get(%d) lost after arena is full", i)
+		}
+	}
+	if !s.Delete(concurrentKey(0)) || s.Get(concurrentKey(0)) != nil || s.Length() != n-1 {
+		t.Errorf("Delete after arena is full failed")
+	}
+	checkSkipList(t, s)
+}
+
+// 写满之后冻结 冻结后的跳表和返回的arena中可以读到所有数据
+func TestArenaSkipList_Freeze(t *testing.T) {
+	s := NewArenaSkipList(1 << 14)
+	value := func(i int) []byte { return []byte(fmt.Sprintf("value-%06d", i)) }
+	n := 0
+	for ; s.Put(concurrentKey(n), value(n)) == nil; n++ {
+	}
+	if n == 0 {
+		t.Fatalf("no keys written")
+	}
+
+	buf := s.Freeze()
+	if len(buf) != s.Size() {
+		t.Errorf("len(Freeze()) = %d, want Size() %d", len(buf), s.Size())
+	}
+	if err := s.Put(concurrentKey(n+1), nil); !errors.Is(err, ErrFrozen) {
+		t.Errorf("Put after Freeze: got %v, want ErrFrozen", err)
+	}
+	if s.Delete(concurrentKey(0)) || s.Length() != n {
+		t.Errorf("Delete after Freeze changed the skiplist")
+	}
+
+	for i := 0; i < n; i++ {
+		if got := s.Get(concurrentKey(i)); !bytes.Equal(got, value(i)) {
+			t.Fatalf("Get(%d) = %q after Freeze", i, got)
+		}
+		if !bytes.Contains(buf, value(i)) {
+			t.Fatalf("value %d not in frozen arena", i)
+		}
+	}
+	i := 0
+	for k, v := range s.All() {
+		if !bytes.Equal(k, concurrentKey(i)) || !bytes.Equal(v, value(i)) {
+			t.Fatalf("All() got %v=%q at %d", k, v, i)
+		}
+		i++
+	}
+	if i != n {
+		t.Errorf("All() returned %d keys, want %d", i, n)
+	}
+	if len(s.Freeze()) != len(buf) {
+		t.Errorf("second Freeze changed the arena")
+	}
+}
+
+// 写入者还在运行时冻结 Freeze返回之后arena不再改变
+func TestArenaSkipList_FreezeConcurrent(t *testing.T) {
+	s := NewArenaSkipList(64 << 20)
+	var wg sync.WaitGroup
+	var stop atomic.Bool
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; !stop.Load(); i += 4 {
+				if s.Put(concurrentKey(i%100000), concurrentKey(i)) != nil {
+					return
+				}
+				s.Delete(concurrentKey((i + 7) % 100000))
+			}
+		}(w)
+	}
+	for s.Length() < 1000 {
+		runtime.Gosched()
+	}
+
+	buf := s.Freeze()
+	frozen, size := bytes.Clone(buf), s.Size()
+	stop.Store(true)
+	wg.Wait()
+	if !bytes.Equal(buf, frozen) || s.Size() != size || len(buf) != size {
+		t.Errorf("arena changed after Freeze returned")
+	}
+	checkSkipList(t, s)
+}
@@ -0,0 +1,136 @@
+package skiplist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// internal key: 用户key + 8字节序列号 用户key升序 同一个用户key序列号降序(新版本在前)
+func internalKey(key string, seq uint64) []byte {
+	b := append([]byte(key), make([]byte, 8)...)
+	binary.BigEndian.PutUint64(b[len(key):], seq)
+	return b
+}
+
+func compareInternalKey(a, b []byte) int {
+	if r := bytes.Compare(a[:len(a)-8], b[:len(b)-8]); r != 0 {
+		return r
+	}
+	return -bytes.Compare(a[len(a)-8:], b[len(b)-8:])
+}
+
+func internalKeys(all func(func([]byte, []byte) bool)) []string {
+	var keys []string
+	for k := range all {
+		keys = append(keys, string(k[:len(k)-8])+"@"+string('0'+k[len(k)-1]))
+	}
+	return keys
+}
+
+func TestSkipListFunc_InternalKey(t *testing.T) {
+	want := []string{"a@3", "a@2", "a@1", "b@5", "b@4"}
+	sl := NewSkipListFunc[[]byte, []byte](compareInternalKey)
+	csl := NewConcurrentSkipListFunc(compareInternalKey)
+	asl := NewArenaSkipListFunc(1<<16, compareInternalKey)
+	for _, e := range []struct {
+		key string
+		seq uint64
+	}{{"b", 4}, {"a", 1}, {"a", 3}, {"b", 5}, {"a", 2}} {
+		k := internalKey(e.key, e.seq)
+		sl.Put(k, []byte(e.key))
+		csl.Put(k, []byte(e.key))
+		if err := asl.Put(k, []byte(e.key)); err != nil {
+			t.Fatalf("Put: %s", err)
+		}
+	}
+
+	for name, all := range map[string]func(func([]byte, []byte) bool){
+		"SkipList":           sl.All(),
+		"ConcurrentSkipList": csl.All(),
+		"ArenaSkipList":      asl.All(),
+	} {
+		if got := internalKeys(all); !slices.Equal(got, want) {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+
+	// Seek到用户key的最大序列号 找到小于等于这个序列号的最新版本
+	ski := sl.Iterator()
+	ski.Seek(internalKey("a", 2))
+	if got := internalKeys(func(yield func([]byte, []byte) bool) { yield(ski.Key(), nil) }); got[0] != "a@2" {
+		t.Errorf("Seek got %v, want a@2", got)
+	}
+	ski.Seek(internalKey("b", 9))
+	if !bytes.Equal(ski.Key(), internalKey("b", 5)) {
+		t.Errorf("Seek got %v, want b@5", ski.Key())
+	}
+	for _, get := range []func([]byte) []byte{sl.Get, csl.Get, asl.Get} {
+		if string(get(internalKey("a", 3))) != "a" || get(internalKey("a", 4)) != nil {
+			t.Errorf("Get with comparator failed")
+		}
+	}
+}
+
+func TestSkipListFunc_Generic(t *testing.T) {
+	// 不区分大小写的字符串
+	sl := NewSkipListFunc[string, int](func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+	sl.Put("Banana", 1)
+	sl.Put("apple", 2)
+	sl.Put("BANANA", 3)
+	sl.Put("Cherry", 4)
+	var keys []string
+	var values []int
+	for k, v := range sl.All() {
+		keys, values = append(keys, k), append(values, v)
+	}
+	if !slices.Equal(keys, []string{"apple", "Banana", "Cherry"}) || !slices.Equal(values, []int{2, 3, 4}) {
+		t.Errorf("got %v %v", keys, values)
+	}
+	if sl.Get("banana") != 3 || sl.Get("durian") != 0 || sl.Length() != 3 {
+		t.Errorf("Get/Length failed")
+	}
+	sl.Delete("CHERRY")
+	if sl.Length() != 2 || sl.Size() != len("apple")+len("Banana")+2*(8+8) {
+		t.Errorf("Length()/Size() = %d/%d after Delete", sl.Length(), sl.Size())
+	}
+
+	// 默认顺序
+	nums := NewSkipList[int, string]()
+	for _, n := range []int{5, -1, 3} {
+		nums.Put(n, "")
+	}
+	var got []int
+	for k := range nums.Range(0, 10) {
+		got = append(got, k)
+	}
+	if !slices.Equal(got, []int{3, 5}) {
+		t.Errorf("Range(0, 10) = %v, want [3 5]", got)
+	}
+	// 零值是普通的上界 不限制上界用RangeFrom
+	got = got[:0]
+	for k := range nums.Range(-5, 0) {
+		got = append(got, k)
+	}
+	if !slices.Equal(got, []int{-1}) {
+		t.Errorf("Range(-5, 0) = %v, want [-1]", got)
+	}
+	got = got[:0]
+	for k := range nums.RangeFrom(0) {
+		got = append(got, k)
+	}
+	if !slices.Equal(got, []int{3, 5}) {
+		t.Errorf("RangeFrom(0) = %v, want [3 5]", got)
+	}
+	got = got[:0]
+	for k := range nums.Backward() {
+		got = append(got, k)
+	}
+	if !slices.Equal(got, []int{5, 3, -1}) {
+		t.Errorf("Backward() = %v, want [5 3 -1]", got)
+	}
+}
@@ -0,0 +1,306 @@
+package skiplist
+
+import (
+	"bytes"
+	"iter"
+	"sync/atomic"
+)
+
+// *********************** 并发跳表 ***********************
+/*
+ConcurrentSkipList是无锁的跳表,多个goroutine可以同时Put/Delete/Get和遍历,用作多个写入者的memtable:
+1. 每一层的next指针是不可变的link{node, marked},用CAS整体替换,marked表示link的所有者正在被删除,
+   被标记的link不能再修改,保证不会在正在删除的节点后面插入新节点(Harris链表)。
+2. value为nil表示节点已经被删除:Delete用CAS把value置为nil(删除在这一步生效),
+   然后从高层到低层标记节点的每一层next,最后调用find把节点从每一层摘除。
+   Put遇到value为nil的节点时帮助标记,等节点摘除后再插入新节点,不会等待其他goroutine。
+3. Put新节点时先用CAS链接最底层(插入在这一步生效),再逐层链接高层,
+   链接期间节点被删除时停止,残留在高层的已标记节点由之后经过的find摘除。
+4. Get和遍历只读,跳过被标记或value为nil的节点,不修改任何指针。
+   遍历看到的是弱一致的结果:遍历期间的修改可能看到也可能看不到,但key总是递增且不重复。
+节点摘除后由GC回收,不存在ABA问题。
+*/
+
+type ConcurrentSkipList struct {
+	length  atomic.Int64          // key数量
+	size    atomic.Int64          // 字节大小 计算方式与SkipList相同
+	head    *concurrentNode       // 头节点是伪节点 不计数 不参与计算
+	compare func(a, b []byte) int // key比较函数 默认bytes.Compare
+	levels  *levelGenerator       // 生成随机层级
+}
+
+type concurrentNode struct {
+	key   []byte
+	value atomic.Pointer[[]byte] // nil表示已经删除
+	next  []atomic.Pointer[link]
+}
+
+// 某一层指向下一个节点的指针 创建之后不再修改
+type link struct {
+	node   *concurrentNode
+	marked bool // 所有者节点在这一层已经被删除
+}
+
+// NewConcurrentSkipList :构造函数 key按bytes.Compare比较
+func NewConcurrentSkipList(opts ...Option) *ConcurrentSkipList {
+	return NewConcurrentSkipListFunc(bytes.Compare, opts...)
+}
+
+// NewConcurrentSkipListFunc :构造函数 使用自定义的key比较函数 比如internal key的顺序
+func NewConcurrentSkipListFunc(compare func(a, b []byte) int, opts ...Option) *ConcurrentSkipList {
+	c := newConfig(opts)
+	head := &concurrentNode{next: make([]atomic.Pointer[link], c.maxLevel)}
+	for i := range head.next {
+		head.next[i].Store(&link{})
+	}
+	return &ConcurrentSkipList{head: head, compare: compare, levels: newLevelGenerator(c)}
+}
+
+// Put :插入key key已经存在时替换value
+func (sl *ConcurrentSkipList) Put(key, value []byte) {
+	var preds, succs [maxLevelLimit]*concurrentNode
+	for {
+		if sl.find(key, &preds, &succs) {
+			// 1.key已经存在 替换value 节点正在被删除时帮助标记后重试
+			node := succs[0]
+			old := node.value.Load()
+			if old == nil {
+				node.mark()
+				continue
+			}
+			if node.value.CompareAndSwap(old, &value) {
+				sl.size.Add(int64(len(value) - len(*old)))
+				return
+			}
+			continue
+		}
+
+		// 2.链接最底层 失败说明前后节点有变化 重新查找
+		level := sl.levels.level()
+		node := &concurrentNode{key: key, next: make([]atomic.Pointer[link], level)}
+		node.value.Store(&value)
+		for i := 0; i < level; i++ {
+			node.next[i].Store(&link{node: succs[i]})
+		}
+		if !preds[0].casNext(0, succs[0], node) {
+			continue
+		}
+		sl.size.Add(int64(len(key) + len(value) + 8))
+		sl.length.Add(1)
+
+		// 3.逐层链接高层
+		sl.linkLevels(node, &preds, &succs)
+		return
+	}
+}
+
+// 把已经链接最底层的node链接到高层 node被删除时停止
+func (sl *ConcurrentSkipList) linkLevels(node *concurrentNode, preds, succs *[maxLevelLimit]*concurrentNode) {
+	for i := 1; i < len(node.next); i++ {
+		for {
+			next := node.next[i].Load()
+			if next.marked {
+				return
+			}
+			if next.node != succs[i] && !node.next[i].CompareAndSwap(next, &link{node: succs[i]}) {
+				continue
+			}
+			if preds[i].casNext(i, succs[i], node) {
+				break
+			}
+			if !sl.find(node.key, preds, succs) || succs[0] != node {
+				return
+			}
+		}
+	}
+}
+
+// Get :查找key 不存在时返回nil
+func (sl *ConcurrentSkipList) Get(key []byte) []byte {
+	node := sl.findGreaterOrEqual(key, true)
+	if node == nil || sl.compare(node.key, key) != 0 {
+		return nil
+	}
+	if value := node.value.Load(); value != nil {
+		return *value
+	}
+	return nil
+}
+
+// Delete :删除key 返回key是否存在
+func (sl *ConcurrentSkipList) Delete(key []byte) bool {
+	var preds, succs [maxLevelLimit]*concurrentNode
+	for {
+		if !sl.find(key, &preds, &succs) {
+			return false
+		}
+		node := succs[0]
+		old := node.value.Load()
+		if old == nil {
+			// 其他goroutine已经删除
+			node.mark()
+			return false
+		}
+		if !node.value.CompareAndSwap(old, nil) {
+			// value被Put替换 重试
+			continue
+		}
+
+		sl.size.Add(-int64(len(key) + len(*old) + 8))
+		sl.length.Add(-1)
+		node.mark()
+		sl.find(key, &preds, &succs)
+		return true
+	}
+}
+
+// All :按key从小到大遍历 可以直接用于for range 遍历期间可以并发修改
+func (sl *ConcurrentSkipList) All() iter.Seq2[[]byte, []byte] {
+	return sl.scan(nil, nil, false, false)
+}
+
+// Backward :按key从大到小遍历
+/*
+与SkipList.Backward相同,每一步都从头节点查找比当前key小的最后一个节点。
+*/
+func (sl *ConcurrentSkipList) Backward() iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		var key []byte
+		for p := sl.findLess(nil, false); p != nil; p = sl.findLess(key, true) {
+			key = p.key
+			if value := p.value.Load(); value != nil && !yield(p.key, *value) {
+				return
+			}
+		}
+	}
+}
+
+// Range :按key从小到大遍历lo<=key<hi的数据 nil和其他key一样比较
+func (sl *ConcurrentSkipList) Range(lo, hi []byte) iter.Seq2[[]byte, []byte] {
+	return sl.scan(lo, hi, true, true)
+}
+
+// RangeFrom :按key从小到大遍历key>=lo的数据 不限制上界
+func (sl *ConcurrentSkipList) RangeFrom(lo []byte) iter.Seq2[[]byte, []byte] {
+	return sl.scan(lo, nil, true, false)
+}
+
+// 遍历lo<=key<hi的数据 hasLo/hasHi为false时不限制下界/上界
+func (sl *ConcurrentSkipList) scan(lo, hi []byte, hasLo, hasHi bool) iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		for p := sl.findGreaterOrEqual(lo, hasLo); p != nil; p = p.next[0].Load().node {
+			if hasHi && sl.compare(p.key, hi) >= 0 {
+				return
+			}
+			if p.next[0].Load().marked {
+				continue
+			}
+			if value := p.value.Load(); value != nil && !yield(p.key, *value) {
+				return
+			}
+		}
+	}
+}
+
+func (sl *ConcurrentSkipList) Size() int {
+	return int(sl.size.Load())
+}
+
+func (sl *ConcurrentSkipList) Length() int {
+	return int(sl.length.Load())
+}
+
+// 查找每一层最后一个key<key的节点preds和它的下一个节点succs 返回最底层是否找到key
+/*
+经过已经标记的节点时用CAS把它从这一层摘除,CAS失败说明前一个节点有变化,从头重新查找。
+*/
+func (sl *ConcurrentSkipList) find(key []byte, preds, succs *[maxLevelLimit]*concurrentNode) bool {
+retry:
+	pred := sl.head
+	for i := len(sl.head.next) - 1; i >= 0; i-- {
+		predNext := pred.next[i].Load()
+		if predNext.marked {
+			goto retry
+		}
+		curr := predNext.node
+		for curr != nil {
+			next := curr.next[i].Load()
+			if next.marked {
+				snipped := &link{node: next.node}
+				if !pred.next[i].CompareAndSwap(predNext, snipped) {
+					goto retry
+				}
+				predNext, curr = snipped, next.node
+				continue
+			}
+			if sl.compare(curr.key, key) >= 0 {
+				break
+			}
+			pred, predNext, curr = curr, next, next.node
+		}
+		preds[i], succs[i] = pred, curr
+	}
+	return succs[0] != nil && sl.compare(succs[0].key, key) == 0
+}
+
+// 第一个key>=key的未删除节点 bounded为false时返回第一个节点 只读
+func (sl *ConcurrentSkipList) findGreaterOrEqual(key []byte, bounded bool) *concurrentNode {
+	pred := sl.head
+	var curr *concurrentNode
+	for i := len(sl.head.next) - 1; i >= 0; i-- {
+		curr = pred.next[i].Load().node
+		for curr != nil {
+			next := curr.next[i].Load()
+			if next.marked {
+				curr = next.node
+				continue
+			}
+			if !bounded || sl.compare(curr.key, key) >= 0 {
+				break
+			}
+			pred, curr = curr, next.node
+		}
+	}
+	return curr
+}
+
+// 最后一个key<key的未删除节点 bounded为false时返回最后一个节点 只读
+func (sl *ConcurrentSkipList) findLess(key []byte, bounded bool) *concurrentNode {
+	pred := sl.head
+	for i := len(sl.head.next) - 1; i >= 0; i-- {
+		curr := pred.next[i].Load().node
+		for curr != nil {
+			next := curr.next[i].Load()
+			if next.marked {
+				curr = next.node
+				continue
+			}
+			if bounded && sl.compare(curr.key, key) >= 0 {
+				break
+			}
+			pred, curr = curr, next.node
+		}
+	}
+	if pred == sl.head {
+		return nil
+	}
+	return pred
+}
+
+// 第i层的下一个节点是succ且没有标记时替换为node
+func (n *concurrentNode) casNext(i int, succ, node *concurrentNode) bool {
+	next := n.next[i].Load()
+	return !next.marked && next.node == succ && n.next[i].CompareAndSwap(next, &link{node: node})
+}
+
+// 从高层到低层标记每一层 已经标记的层不变 可以由多个goroutine同时调用
+func (n *concurrentNode) mark() {
+	for i := len(n.next) - 1; i >= 0; i-- {
+		for {
+			next := n.next[i].Load()
+			if next.marked || n.next[i].CompareAndSwap(next, &link{node: next.node, marked: true}) {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package skiplist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"iter"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func concurrentKey(i int) []byte {
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, uint32(i))
+	return key
+}
+
+// ConcurrentSkipList和ArenaSkipList共同的方法 两种跳表用同一组模型测试检查
+type byteSkipList interface {
+	Put(key, value []byte) error
+	Get(key []byte) []byte
+	Delete(key []byte) bool
+	All() iter.Seq2[[]byte, []byte]
+	Backward() iter.Seq2[[]byte, []byte]
+	Range(lo, hi []byte) iter.Seq2[[]byte, []byte]
+	RangeFrom(lo []byte) iter.Seq2[[]byte, []byte]
+	Length() int
+}
+
+// ConcurrentSkipList的Put不返回错误
+type concurrentList struct{ *ConcurrentSkipList }
+
+func (l concurrentList) Put(key, value []byte) error {
+	l.ConcurrentSkipList.Put(key, value)
+	return nil
+}
+
+func newByteSkipLists() map[string]byteSkipList {
+	return map[string]byteSkipList{
+		"Concurrent": concurrentList{NewConcurrentSkipList()},
+		"Arena":      NewArenaSkipList(64 << 20),
+	}
+}
+
+// 检查遍历结果有序不重复 Length与遍历结果一致 ConcurrentSkipList的Size也与遍历结果一致
+func checkSkipList(t *testing.T, sl byteSkipList) map[string][]byte {
+	t.Helper()
+	got := make(map[string][]byte)
+	size := 0
+	var prev []byte
+	for k, v := range sl.All() {
+		if prev != nil && bytes.Compare(prev, k) >= 0 {
+			t.Fatalf("keys out of order: %v before %v", prev, k)
+		}
+		prev = k
+		got[string(k)] = v
+		size += len(k) + len(v) + 8
+	}
+	if sl.Length() != len(got) {
+		t.Fatalf("Length() = %d, want %d", sl.Length(), len(got))
+	}
+	if l, ok := sl.(concurrentList); ok && l.Size() != size {
+		t.Fatalf("Size() = %d, want %d", l.Size(), size)
+	}
+	backward := 0
+	for k := range sl.Backward() {
+		if _, ok := got[string(k)]; !ok {
+			t.Fatalf("Backward() yielded unknown key %v", k)
+		}
+		backward++
+	}
+	if backward != len(got) {
+		t.Fatalf("Backward() got %d keys, want %d", backward, len(got))
+	}
+	return got
+}
+
+func TestByteSkipList_Model(t *testing.T) {
+	for name, sl := range newByteSkipLists() {
+		t.Run(name, func(t *testing.T) { testModel(t, sl) })
+	}
+}
+
+func testModel(t *testing.T, sl byteSkipList) {
+	model := make(map[string][]byte)
+	for i := 0; i < 20000; i++ {
+		key := concurrentKey(rand.Intn(2000))
+		switch rand.Intn(3) {
+		case 0:
+			_, ok := model[string(key)]
+			if sl.Delete(key) != ok {
+				t.Fatalf("Delete(%v) = %v, want %v", key, !ok, ok)
+			}
+			delete(model, string(key))
+		default:
+			value := bytes.Repeat([]byte{byte(i)}, rand.Intn(8))
+			if err := sl.Put(key, value); err != nil {
+				t.Fatalf("Put(%v): %s", key, err)
+			}
+			model[string(key)] = value
+		}
+		if got, want := sl.Get(key), model[string(key)]; !bytes.Equal(got, want) {
+			t.Fatalf("Get(%v) = %v, want %v", key, got, want)
+		}
+	}
+
+	got := checkSkipList(t, sl)
+	if len(got) != len(model) {
+		t.Fatalf("got %d keys, want %d", len(got), len(model))
+	}
+	for k, v := range model {
+		if !bytes.Equal(got[k], v) {
+			t.Fatalf("key %v = %v, want %v", []byte(k), got[k], v)
+		}
+	}
+
+	var keys []int
+	for k := range sl.Range(concurrentKey(100), concurrentKey(110)) {
+		keys = append(keys, int(binary.BigEndian.Uint32(k)))
+	}
+	for i, key := range keys {
+		if _, ok := model[string(concurrentKey(key))]; !ok || key < 100 || key >= 110 || (i > 0 && keys[i-1] >= key) {
+			t.Fatalf("unexpected Range() keys %v", keys)
+		}
+	}
+	n := 0
+	for k := range sl.RangeFrom(concurrentKey(100)) {
+		if bytes.Compare(k, concurrentKey(100)) < 0 {
+			t.Fatalf("unexpected RangeFrom() key %v", k)
+		}
+		n++
+	}
+	for k := range model {
+		if k >= string(concurrentKey(100)) {
+			n--
+		}
+	}
+	if n != 0 {
+		t.Errorf("RangeFrom() returned %d keys more than the model", n)
+	}
+}
+
+// 每个写入者修改自己的key 另外几个goroutine争用同一组key 同时有读取者遍历 最后与每个写入者的模型对比
+func TestByteSkipList_Stress(t *testing.T) {
+	for name, sl := range newByteSkipLists() {
+		t.Run(name, func(t *testing.T) { testStress(t, sl) })
+	}
+}
+
+func testStress(t *testing.T, sl byteSkipList) {
+	const writers, keys = 8, 1000
+	ops := 20000
+	if testing.Short() {
+		ops = 5000
+	}
+	models := make([]map[string][]byte, writers)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for w := 0; w < writers; w++ {
+		models[w] = make(map[string][]byte)
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(w)))
+			model := models[w]
+			for i := 0; i < ops; i++ {
+				key := concurrentKey(r.Intn(keys)*writers + w)
+				if r.Intn(3) == 0 {
+					_, ok := model[string(key)]
+					if sl.Delete(key) != ok {
+						t.Errorf("Delete(%v) = %v, want %v", key, !ok, ok)
+						return
+					}
+					delete(model, string(key))
+				} else {
+					value := []byte{byte(i), byte(w)}
+					if err := sl.Put(key, value); err != nil {
+						t.Errorf("Put(%v): %s", key, err)
+						return
+					}
+					model[string(key)] = value
+				}
+				if got := sl.Get(key); !bytes.Equal(got, model[string(key)]) {
+					t.Errorf("Get(%v) = %v, want %v", key, got, model[string(key)])
+					return
+				}
+			}
+		}(w)
+	}
+
+	// 争用的key以0xff开头 排在所有写入者的key之后
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(w)))
+			for i := 0; i < ops/4; i++ {
+				key := []byte{0xff, byte(r.Intn(32))}
+				if r.Intn(2) == 0 {
+					sl.Delete(key)
+				} else if err := sl.Put(key, make([]byte, r.Intn(8))); err != nil {
+					t.Errorf("Put(%v): %s", key, err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	var readers sync.WaitGroup
+	for r := 0; r < 2; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				var prev []byte
+				for k := range sl.All() {
+					if prev != nil && bytes.Compare(prev, k) >= 0 {
+						t.Errorf("keys out of order: %v before %v", prev, k)
+						return
+					}
+					prev = k
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	readers.Wait()
+
+	got := checkSkipList(t, sl)
+	total := 0
+	for _, model := range models {
+		total += len(model)
+		for k, v := range model {
+			if !bytes.Equal(got[k], v) {
+				t.Fatalf("key %v = %v, want %v", []byte(k), got[k], v)
+			}
+		}
+	}
+	for k := range got {
+		if k[0] == 0xff {
+			total++
+		}
+	}
+	if len(got) != total {
+		t.Fatalf("got %d keys, want %d", len(got), total)
+	}
+}
+
+// 所有goroutine争用同一组key Put/Delete交替进行
+func TestConcurrentSkipList_Contention(t *testing.T) {
+	sl := NewConcurrentSkipList()
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(w)))
+			for i := 0; i < 5000; i++ {
+				key := concurrentKey(r.Intn(32))
+				if r.Intn(2) == 0 {
+					sl.Delete(key)
+				} else {
+					sl.Put(key, make([]byte, r.Intn(8)))
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	checkSkipList(t, concurrentList{sl})
+
+	// 删除所有key之后每一层都是空的
+	for i := 0; i < 32; i++ {
+		sl.Delete(concurrentKey(i))
+	}
+	checkSkipList(t, concurrentList{sl})
+	for i := range sl.head.next {
+		if next := sl.head.next[i].Load().node; next != nil {
+			t.Fatalf("level %d still links %v", i, next.key)
+		}
+	}
+}
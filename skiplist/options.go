@@ -0,0 +1,79 @@
+package skiplist
+
+import (
+	"fmt"
+	"math/rand"
+	"unsafe"
+)
+
+// Option :跳表的可选配置 在NewSkipList/NewConcurrentSkipList/NewArenaSkipList时传入
+type Option func(*config)
+
+type config struct {
+	maxLevel    int         // 最大层级
+	levelFactor float64     // 生成层级的因子
+	source      rand.Source // 生成层级的随机数 为nil时使用xorshift
+	seed        uint64      // xorshift的种子
+	seeded      bool        // 是否设置了种子
+}
+
+func newConfig(opts []Option) config {
+	c := config{maxLevel: MaxLevel, levelFactor: LevelFactor}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.maxLevel < 1 || c.maxLevel > maxLevelLimit {
+		panic(fmt.Sprintf("skiplist: max level must be in [1, %d]", maxLevelLimit))
+	}
+	if c.levelFactor <= 0 || c.levelFactor >= 1 {
+		panic("skiplist: level factor must be in (0, 1)")
+	}
+	return c
+}
+
+// WithMaxLevel :设置最大层级 取值[1, 32] 默认MaxLevel
+/*
+最大层级和因子决定了跳表适合的数据量,大约为(1/factor)^maxLevel,
+默认的16层、0.5适合6万左右的key,更大的memtable可以调高最大层级。
+*/
+func WithMaxLevel(level int) Option {
+	return func(c *config) {
+		c.maxLevel = level
+	}
+}
+
+// WithLevelFactor :设置生成层级的因子(节点出现在上一层的概率) 取值(0,1) 默认LevelFactor
+/*
+因子越小每个节点的平均层数越少(1/(1-factor)),占用的内存越少,查找时每一层比较的次数越多。
+*/
+func WithLevelFactor(f float64) Option {
+	return func(c *config) {
+		c.levelFactor = f
+	}
+}
+
+// WithSeed :固定生成层级的随机数种子 用于测试中得到确定的结构
+func WithSeed(seed uint64) Option {
+	return func(c *config) {
+		c.seed, c.seeded = seed, true
+	}
+}
+
+// WithRandSource :使用src生成层级 src只被这个跳表使用 调用时加锁
+func WithRandSource(src rand.Source) Option {
+	return func(c *config) {
+		c.source = src
+	}
+}
+
+// 计算Size时的字节数 []byte和string是长度 其他类型是类型本身的大小
+func sizer[T any]() func(T) int {
+	switch any(*new(T)).(type) {
+	case []byte:
+		return any(func(b []byte) int { return len(b) }).(func(T) int)
+	case string:
+		return any(func(s string) int { return len(s) }).(func(T) int)
+	}
+	size := int(unsafe.Sizeof(*new(T)))
+	return func(T) int { return size }
+}
@@ -0,0 +1,85 @@
+package skiplist
+
+import (
+	"math/rand"
+	randv2 "math/rand/v2"
+	"sync"
+	"sync/atomic"
+)
+
+// *********************** 随机层级 ***********************
+/*
+每个跳表有自己的随机数生成器,不使用也不修改math/rand的全局source:
+1. 默认使用xorshift64,种子来自math/rand/v2(每个进程、每个跳表都不同),
+   状态用CAS更新,ConcurrentSkipList/ArenaSkipList的多个写入者可以同时调用。
+2. WithSeed固定xorshift的种子,WithRandSource使用调用者的rand.Source,
+   相同的种子和相同的插入顺序得到相同的层级,用于测试中复现问题。
+   rand.Source不是并发安全的,调用时加锁。
+*/
+
+// 生成随机层级 层级为k+1的概率是k的factor倍 不超过maxLevel
+type levelGenerator struct {
+	maxLevel int
+	factor   float64
+	state    atomic.Uint64 // xorshift的状态 不为0
+	mu       sync.Mutex    // 保护source
+	source   rand.Source   // 不为nil时代替xorshift
+}
+
+func newLevelGenerator(c config) *levelGenerator {
+	g := &levelGenerator{maxLevel: c.maxLevel, factor: c.levelFactor, source: c.source}
+	seed := c.seed
+	if !c.seeded {
+		seed = randv2.Uint64()
+	}
+	g.state.Store(splitmix64(seed))
+	return g
+}
+
+func (g *levelGenerator) level() int {
+	l := 1
+	for l < g.maxLevel && g.float64() < g.factor {
+		l++
+	}
+	return l
+}
+
+// [0, 1)之间的随机数
+func (g *levelGenerator) float64() float64 {
+	return float64(g.uint64()>>11) / (1 << 53)
+}
+
+func (g *levelGenerator) uint64() uint64 {
+	if g.source != nil {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if s, ok := g.source.(rand.Source64); ok {
+			return s.Uint64()
+		}
+		return uint64(g.source.Int63()) << 1
+	}
+
+	for {
+		old := g.state.Load()
+		x := old
+		x ^= x << 13
+		x ^= x >> 7
+		x ^= x << 17
+		if g.state.CompareAndSwap(old, x) {
+			return x
+		}
+	}
+}
+
+// 把种子打散为xorshift的初始状态 相近的种子得到差别很大的状态
+func splitmix64(seed uint64) uint64 {
+	z := seed + 0x9e3779b97f4a7c15
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	z ^= z >> 31
+	if z == 0 {
+		// xorshift的状态不能为0
+		z = 1
+	}
+	return z
+}
@@ -0,0 +1,133 @@
+package skiplist
+
+import (
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// 按key顺序每个节点的层数
+func heights[K any, V any](sl *SkipList[K, V]) []int {
+	var h []int
+	for p := sl.head.forward[0]; p != nil; p = p.forward[0] {
+		h = append(h, len(p.forward))
+	}
+	return h
+}
+
+func buildSkipList(n int, opts ...Option) *SkipList[int, int] {
+	sl := NewSkipList[int, int](opts...)
+	for i := 0; i < n; i++ {
+		sl.Put(i, i)
+	}
+	return sl
+}
+
+// 相同的种子得到相同的结构 默认每个跳表不同
+func TestLevelGenerator_Seed(t *testing.T) {
+	a, b := heights(buildSkipList(500, WithSeed(7))), heights(buildSkipList(500, WithSeed(7)))
+	if !slices.Equal(a, b) {
+		t.Errorf("WithSeed(7) produced different levels")
+	}
+	if c := heights(buildSkipList(500, WithSeed(8))); slices.Equal(a, c) {
+		t.Errorf("WithSeed(7) and WithSeed(8) produced the same levels")
+	}
+
+	a = heights(buildSkipList(500, WithRandSource(rand.NewSource(1))))
+	b = heights(buildSkipList(500, WithRandSource(rand.NewSource(1))))
+	if !slices.Equal(a, b) {
+		t.Errorf("WithRandSource produced different levels")
+	}
+
+	if slices.Equal(heights(buildSkipList(500)), heights(buildSkipList(500))) {
+		t.Errorf("two default skiplists produced the same levels")
+	}
+}
+
+func TestLevelGenerator_Options(t *testing.T) {
+	sl := buildSkipList(20000, WithMaxLevel(4), WithLevelFactor(0.25), WithSeed(1))
+	h := heights(sl)
+	total := 0
+	for _, l := range h {
+		if l > 4 {
+			t.Fatalf("node has %d levels, max is 4", l)
+		}
+		total += l
+	}
+	// 平均层数约为1/(1-0.25)
+	if avg := float64(total) / float64(len(h)); avg < 1.25 || avg > 1.42 {
+		t.Errorf("average level = %.3f, want about 1.333", avg)
+	}
+	for i := 0; i < 20000; i += 997 {
+		if sl.Get(i) != i {
+			t.Fatalf("Get(%d) failed", i)
+		}
+	}
+
+	// 超过默认MaxLevel
+	tall := buildSkipList(1000, WithMaxLevel(maxLevelLimit), WithLevelFactor(0.9), WithSeed(1))
+	if len(tall.head.forward) != maxLevelLimit || slices.Max(heights(tall)) <= MaxLevel {
+		t.Errorf("WithMaxLevel(%d) did not produce levels above %d", maxLevelLimit, MaxLevel)
+	}
+
+	csl := NewConcurrentSkipList(WithMaxLevel(3), WithSeed(1))
+	asl := NewArenaSkipList(1<<20, WithMaxLevel(3), WithSeed(1))
+	for i := 0; i < 1000; i++ {
+		csl.Put(concurrentKey(i), nil)
+		if err := asl.Put(concurrentKey(i), nil); err != nil {
+			t.Fatalf("Put: %s", err)
+		}
+	}
+	if len(csl.head.next) != 3 || asl.node(asl.head).height != 3 {
+		t.Errorf("WithMaxLevel(3) not applied")
+	}
+	for p := csl.head.next[0].Load().node; p != nil; p = p.next[0].Load().node {
+		if len(p.next) > 3 {
+			t.Fatalf("node has %d levels, max is 3", len(p.next))
+		}
+	}
+	for p := asl.node(asl.node(asl.head).tower[0].Load()); p != nil; p = asl.node(p.tower[0].Load()) {
+		if p.height > 3 {
+			t.Fatalf("arena node has %d levels, max is 3", p.height)
+		}
+	}
+	if checkSkipList(t, concurrentList{csl}); len(checkSkipList(t, asl)) != 1000 {
+		t.Errorf("expected 1000 keys")
+	}
+
+	for name, opt := range map[string]Option{
+		"max level 0":  WithMaxLevel(0),
+		"max level 33": WithMaxLevel(maxLevelLimit + 1),
+		"factor 0":     WithLevelFactor(0),
+		"factor 1":     WithLevelFactor(1),
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", name)
+				}
+			}()
+			NewSkipList[int, int](opt)
+		}()
+	}
+}
+
+// rand.Source不是并发安全的 并发跳表的多个写入者共用时加锁
+func TestLevelGenerator_ConcurrentSource(t *testing.T) {
+	sl := NewConcurrentSkipList(WithRandSource(rand.NewSource(1)))
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < 4000; i += 4 {
+				sl.Put(concurrentKey(i), nil)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if len(checkSkipList(t, concurrentList{sl})) != 4000 {
+		t.Errorf("expected 4000 keys")
+	}
+}
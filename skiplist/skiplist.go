@@ -1,57 +1,86 @@
+// Package skiplist :跳表 包括泛型的SkipList、无锁的ConcurrentSkipList和arena分配的ArenaSkipList
+/*
+构造函数与bptree的NewTree/NewTreeFunc相同,比较函数的类型在编译时检查:
+1. NewSkipList[K, V]()用于可排序的key(string、数字等cmp.Ordered类型),按cmp.Compare比较。
+2. NewSkipListFunc[K, V](compare)用于[]byte等不能直接比较的key或者自定义的顺序,
+   NewConcurrentSkipListFunc/NewArenaSkipListFunc同理,不传比较函数时按bytes.Compare比较。
+
+从之前的版本迁移:
+1. 原来的NewSkipList()(key和value都是[]byte)改为NewSkipListFunc[[]byte, []byte](bytes.Compare)。
+2. WithComparator(fn)已经删除,改为把fn传给对应的...Func构造函数。
+3. SkipList.Range(lo, nil)不再表示不限制上界,改用RangeFrom(lo)。
+*/
 package skiplist
 
 import (
-	"bytes"
+	"cmp"
 	"fmt"
-	"math/rand"
+	"iter"
 )
 
-// 定义了最大的层级限制
+// 定义了默认的最大层级限制 可以用WithMaxLevel修改
 const MaxLevel = 16
 
-// 定义生成层级的因子
+// 定义生成层级的默认因子 可以用WithLevelFactor修改
 const LevelFactor = 0.5
 
-type SkipList struct {
-	level  int   // 当前最高层级
-	length int   // key数量
-	size   int   // Skiplist字节大小
-	head   *Node // 头节点是伪节点 不计数 不参与计算
+// WithMaxLevel允许的最大层级
+const maxLevelLimit = 32
+
+type SkipList[K any, V any] struct {
+	level     int               // 当前最高层级
+	length    int               // key数量
+	size      int               // Skiplist字节大小
+	head      *Node[K, V]       // 头节点是伪节点 不计数 不参与计算
+	compare   func(a, b K) int  // key比较函数 a<b返回负数 a==b返回0 a>b返回正数
+	keySize   func(key K) int   // 计算Size时key的字节数
+	valueSize func(value V) int // 计算Size时value的字节数
+	levels    *levelGenerator   // 生成随机层级
+}
+
+type Node[K any, V any] struct {
+	key     K
+	value   V
+	forward []*Node[K, V]
 }
 
-type Node struct {
-	key     []byte
-	value   []byte
-	forward []*Node
+// NewSkipList :构造函数 key为可排序类型(cmp.Ordered) 按自然顺序比较
+func NewSkipList[K cmp.Ordered, V any](opts ...Option) *SkipList[K, V] {
+	return NewSkipListFunc[K, V](cmp.Compare[K], opts...)
 }
 
-func NewSkipList() *SkipList {
-	sk := &SkipList{
-		head: &Node{
-			forward: make([]*Node, MaxLevel),
+// NewSkipListFunc :构造函数 使用自定义的key比较函数 a<b返回负数 a==b返回0 a>b返回正数
+/*
+用于不能直接比较的key([]byte用bytes.Compare)或自定义顺序,比如带序列号后缀的internal key(序列号降序)、
+不区分大小写的字符串等。
+*/
+func NewSkipListFunc[K any, V any](compare func(a, b K) int, opts ...Option) *SkipList[K, V] {
+	c := newConfig(opts)
+	sk := &SkipList[K, V]{
+		head: &Node[K, V]{
+			forward: make([]*Node[K, V], c.maxLevel),
 		},
+		compare:   compare,
+		keySize:   sizer[K](),
+		valueSize: sizer[V](),
+		levels:    newLevelGenerator(c),
 	}
-	for i := 0; i < MaxLevel; i++ {
-		sk.head.forward[i] = nil
-	}
-	//rand.Seed(time.Now().UnixNano())
-	rand.Seed(123)
 	return sk
 }
 
-func (sl *SkipList) Put(key, value []byte) {
-	route := make([]*Node, MaxLevel)
+func (sl *SkipList[K, V]) Put(key K, value V) {
+	route := make([]*Node[K, V], len(sl.head.forward))
 	p := sl.head
 	for i := sl.level - 1; i >= 0; i-- {
 		for p.forward[i] != nil {
-			r := bytes.Compare(key, p.forward[i].key)
-			if r == -1 {
+			r := sl.compare(key, p.forward[i].key)
+			if r < 0 {
 				break
 			} else if r == 0 {
-				sl.size += len(value) - len(p.forward[i].value)
+				sl.size += sl.valueSize(value) - sl.valueSize(p.forward[i].value)
 				p.forward[i].value = value
 				return
-			} else if r == 1 {
+			} else {
 				p = p.forward[i]
 			}
 		}
@@ -60,10 +89,10 @@ func (sl *SkipList) Put(key, value []byte) {
 	}
 
 	level := sl.randomLevel()
-	node := &Node{
+	node := &Node[K, V]{
 		key:     key,
 		value:   value,
-		forward: make([]*Node, level),
+		forward: make([]*Node[K, V], level),
 	}
 	if level > sl.level {
 		for i := sl.level; i < level; i++ {
@@ -75,47 +104,49 @@ func (sl *SkipList) Put(key, value []byte) {
 		node.forward[i] = route[i].forward[i]
 		route[i].forward[i] = node
 	}
-	sl.size += len(key) + len(value) + 8
+	sl.size += sl.keySize(key) + sl.valueSize(value) + 8
 	sl.length++
 }
 
-func (sl *SkipList) Get(key []byte) []byte {
+// Get :查找key 不存在时返回V的零值
+func (sl *SkipList[K, V]) Get(key K) V {
 	p := sl.head
 	for i := sl.level - 1; i >= 0; i-- {
 		for p.forward[i] != nil {
-			r := bytes.Compare(key, p.forward[i].key)
-			if r == -1 {
+			r := sl.compare(key, p.forward[i].key)
+			if r < 0 {
 				break
 			} else if r == 0 {
 				return p.forward[i].value
-			} else if r == 1 {
+			} else {
 				p = p.forward[i]
 			}
 		}
 	}
-	return nil
+	var zero V
+	return zero
 }
 
-func (sl *SkipList) Delete(key []byte) {
-	update := make([]*Node, MaxLevel)
-	var q *Node
+func (sl *SkipList[K, V]) Delete(key K) {
+	update := make([]*Node[K, V], len(sl.head.forward))
+	var q *Node[K, V]
 	p := sl.head
 	for i := sl.level - 1; i >= 0; i-- {
 		for p.forward[i] != nil {
-			r := bytes.Compare(key, p.forward[i].key)
-			if r == -1 {
+			r := sl.compare(key, p.forward[i].key)
+			if r < 0 {
 				break
 			} else if r == 0 {
 				q = p.forward[i]
 				break
-			} else if r == 1 {
+			} else {
 				p = p.forward[i]
 			}
 		}
 		update[i] = p
 	}
 	if q != nil {
-		sl.size += len(key) - len(p.value) - 8
+		sl.size -= sl.keySize(q.key) + sl.valueSize(q.value) + 8
 		sl.length--
 		for i := 0; i < len(q.forward); i++ {
 			update[i].forward[i] = q.forward[i]
@@ -123,15 +154,104 @@ func (sl *SkipList) Delete(key []byte) {
 	}
 }
 
-func (sl *SkipList) Iterator() *SKIterator {
-	ski := &SKIterator{
+func (sl *SkipList[K, V]) Iterator() *SKIterator[K, V] {
+	ski := &SKIterator[K, V]{
 		sk:   sl,
-		node: &Node{},
+		node: &Node[K, V]{},
 	}
 	return ski
 }
 
-func (sl *SkipList) Print() {
+// All :按key从小到大遍历 可以直接用于for range
+func (sl *SkipList[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for p := sl.head.forward[0]; p != nil; p = p.forward[0] {
+			if !yield(p.key, p.value) {
+				return
+			}
+		}
+	}
+}
+
+// Backward :按key从大到小遍历
+/*
+节点只有向后的指针,每一步都从头节点查找比当前key小的最后一个节点,复杂度O(log n)。
+*/
+func (sl *SkipList[K, V]) Backward() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for p := sl.findLast(); p != nil; p = sl.findLess(p.key) {
+			if !yield(p.key, p.value) {
+				return
+			}
+		}
+	}
+}
+
+// Range :按key从小到大遍历lo<=key<hi的数据
+func (sl *SkipList[K, V]) Range(lo, hi K) iter.Seq2[K, V] {
+	return sl.scan(lo, hi, true)
+}
+
+// RangeFrom :按key从小到大遍历key>=lo的数据 不限制上界
+func (sl *SkipList[K, V]) RangeFrom(lo K) iter.Seq2[K, V] {
+	var hi K
+	return sl.scan(lo, hi, false)
+}
+
+// 从第一个key>=lo的节点开始遍历 bounded为false时忽略hi
+func (sl *SkipList[K, V]) scan(lo, hi K, bounded bool) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for p := sl.findGreaterOrEqual(lo); p != nil; p = p.forward[0] {
+			if bounded && sl.compare(p.key, hi) >= 0 {
+				return
+			}
+			if !yield(p.key, p.value) {
+				return
+			}
+		}
+	}
+}
+
+// 第一个key>=key的节点
+func (sl *SkipList[K, V]) findGreaterOrEqual(key K) *Node[K, V] {
+	p := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for p.forward[i] != nil && sl.compare(p.forward[i].key, key) < 0 {
+			p = p.forward[i]
+		}
+	}
+	return p.forward[0]
+}
+
+// 最后一个key<key的节点
+func (sl *SkipList[K, V]) findLess(key K) *Node[K, V] {
+	p := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for p.forward[i] != nil && sl.compare(p.forward[i].key, key) < 0 {
+			p = p.forward[i]
+		}
+	}
+	if p == sl.head {
+		return nil
+	}
+	return p
+}
+
+// 最后一个节点
+func (sl *SkipList[K, V]) findLast() *Node[K, V] {
+	p := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for p.forward[i] != nil {
+			p = p.forward[i]
+		}
+	}
+	if p == sl.head {
+		return nil
+	}
+	return p
+}
+
+func (sl *SkipList[K, V]) Print() {
 	for i := sl.level - 1; i >= 0; i-- {
 		p := sl.head.forward[i]
 		fmt.Printf("[level-%d] ", i+1)
@@ -144,11 +264,11 @@ func (sl *SkipList) Print() {
 	}
 }
 
-func (sl *SkipList) Size() int {
+func (sl *SkipList[K, V]) Size() int {
 	return sl.size
 }
 
-func (sl *SkipList) Length() int {
+func (sl *SkipList[K, V]) Length() int {
 	return sl.length
 }
 
@@ -158,12 +278,9 @@ func (sl *SkipList) Length() int {
 2 级的概率是 25%
 3 级的概率是 12.5%, 以此类推
 */
-func (sl SkipList) randomLevel() int {
-	l := 1
-	// 使用随机数来决定层级
-	for rand.Float64() < LevelFactor && l+1 < MaxLevel {
-		l++
-	}
+func (sl SkipList[K, V]) randomLevel() int {
+	// 使用跳表自己的随机数来决定层级
+	l := sl.levels.level()
 
 	// 如果层级比当前层级高2级或以上，按照高一级处理，避免浪费
 	if l > sl.level+1 {
@@ -173,40 +290,76 @@ func (sl SkipList) randomLevel() int {
 }
 
 // 迭代器
-type SKIterator struct {
-	sk   *SkipList
-	node *Node
+/*
+节点只有向后的指针,Seek/SeekForPrev/Last/Prev都从头节点查找,复杂度O(log n)。
+*/
+type SKIterator[K any, V any] struct {
+	sk   *SkipList[K, V]
+	node *Node[K, V]
 }
 
-func (ski *SKIterator) First() {
+func (ski *SKIterator[K, V]) First() {
 	if ski.sk != nil {
 		ski.node = ski.sk.head.forward[0]
 	}
 }
 
-func (ski *SKIterator) Next() {
-	if ski.node.forward != nil {
+func (ski *SKIterator[K, V]) Next() {
+	if ski.node != nil && ski.node.forward != nil {
 		ski.node = ski.node.forward[0]
 	}
 }
 
-func (ski *SKIterator) End() bool {
+// Seek :移动到第一个key>=key的节点
+func (ski *SKIterator[K, V]) Seek(key K) {
+	if ski.sk != nil {
+		ski.node = ski.sk.findGreaterOrEqual(key)
+	}
+}
+
+// SeekForPrev :移动到最后一个key<=key的节点
+func (ski *SKIterator[K, V]) SeekForPrev(key K) {
+	if ski.sk == nil {
+		return
+	}
+	if p := ski.sk.findGreaterOrEqual(key); p != nil && ski.sk.compare(p.key, key) == 0 {
+		ski.node = p
+		return
+	}
+	ski.node = ski.sk.findLess(key)
+}
+
+// Last :移动到最后一个节点
+func (ski *SKIterator[K, V]) Last() {
+	if ski.sk != nil {
+		ski.node = ski.sk.findLast()
+	}
+}
+
+// Prev :移动到前一个节点 已经在第一个节点时End()为true
+func (ski *SKIterator[K, V]) Prev() {
+	if ski.sk != nil && ski.node != nil {
+		ski.node = ski.sk.findLess(ski.node.key)
+	}
+}
+
+func (ski *SKIterator[K, V]) End() bool {
 	if ski.node == nil {
 		return true
 	}
 	return false
 }
 
-func (ski *SKIterator) Key() []byte {
+func (ski *SKIterator[K, V]) Key() (key K) {
 	if ski.node != nil {
 		return ski.node.key
 	}
-	return nil
+	return
 }
 
-func (ski *SKIterator) Value() []byte {
+func (ski *SKIterator[K, V]) Value() (value V) {
 	if ski.node != nil {
 		return ski.node.value
 	}
-	return nil
+	return
 }
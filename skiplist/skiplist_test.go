@@ -4,13 +4,16 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"iter"
 	"math/rand"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
 
-var sl *SkipList
+var sl *SkipList[[]byte, []byte]
 
 func TestMain(m *testing.M) {
 	//fmt.Println("write setup code here...") // 测试之前的做一些设置
@@ -69,7 +72,7 @@ func TestSkipList_Delete(t *testing.T) {
 }
 
 func TestSkipList_Find(t *testing.T) {
-	_sl := NewSkipList()
+	_sl := NewSkipListFunc[[]byte, []byte](bytes.Compare)
 	for i := 0; i < 1000000; i++ {
 		key := make([]byte, 4)
 		binary.BigEndian.PutUint32(key, rand.Uint32())
@@ -105,9 +108,196 @@ func TestSkipList_Find(t *testing.T) {
 
 }
 
+func TestSkipList_Iterators(t *testing.T) {
+	_sl := NewSkipListFunc[[]byte, []byte](bytes.Compare)
+	for i := 0; i < 100; i++ {
+		_sl.Put([]byte{byte(i)}, []byte{byte(i * 2)})
+	}
+
+	var all, backward []byte
+	for k, v := range _sl.All() {
+		if v[0] != k[0]*2 {
+			t.Errorf("All() value = %v, want %v", v, k[0]*2)
+		}
+		all = append(all, k[0])
+	}
+	for k := range _sl.Backward() {
+		backward = append(backward, k[0])
+	}
+	if len(all) != 100 || len(backward) != 100 {
+		t.Fatalf("All()/Backward() got %d/%d keys, want 100", len(all), len(backward))
+	}
+	for i := range all {
+		if all[i] != byte(i) || backward[i] != byte(99-i) {
+			t.Fatalf("unexpected order at %d: %v %v", i, all[i], backward[i])
+		}
+	}
+
+	tests := []struct {
+		name   string
+		lo, hi []byte
+		want   []byte
+	}{
+		{name: "10-15", lo: []byte{10}, hi: []byte{15}, want: []byte{10, 11, 12, 13, 14}},
+		{name: "between", lo: []byte{10, 5}, hi: []byte{12, 0}, want: []byte{11, 12}},
+		{name: "empty", lo: []byte{15}, hi: []byte{10}, want: nil},
+		{name: "nil hi", lo: []byte{97}, hi: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []byte
+			for k := range _sl.Range(tt.lo, tt.hi) {
+				got = append(got, k[0])
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Range() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	var from []byte
+	for k := range _sl.RangeFrom([]byte{97}) {
+		from = append(from, k[0])
+	}
+	if !bytes.Equal(from, []byte{97, 98, 99}) {
+		t.Errorf("RangeFrom() = %v, want [97 98 99]", from)
+	}
+
+	// 提前break
+	count := 0
+	for range _sl.Backward() {
+		if count++; count == 3 {
+			break
+		}
+	}
+	if count != 3 {
+		t.Errorf("Backward() did not stop, count = %d", count)
+	}
+	for range NewSkipListFunc[[]byte, []byte](bytes.Compare).Backward() {
+		t.Errorf("Backward() on empty skiplist yielded a key")
+	}
+}
+
+func TestSkipList_Seek(t *testing.T) {
+	_sl := NewSkipListFunc[[]byte, []byte](bytes.Compare)
+	for _, k := range []string{"apple", "apricot", "banana", "blueberry", "cherry"} {
+		_sl.Put([]byte(k), []byte(k))
+	}
+
+	tests := []struct {
+		name              string
+		key               string
+		seek, seekForPrev string // 空字符串表示End
+	}{
+		{name: "exact", key: "banana", seek: "banana", seekForPrev: "banana"},
+		{name: "between", key: "b", seek: "banana", seekForPrev: "apricot"},
+		{name: "before first", key: "a", seek: "apple", seekForPrev: ""},
+		{name: "after last", key: "d", seek: "", seekForPrev: "cherry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ski := _sl.Iterator()
+			ski.Seek([]byte(tt.key))
+			if got := string(ski.Key()); got != tt.seek || ski.End() != (tt.seek == "") {
+				t.Errorf("Seek(%q) = %q, want %q", tt.key, got, tt.seek)
+			}
+			ski.SeekForPrev([]byte(tt.key))
+			if got := string(ski.Key()); got != tt.seekForPrev || ski.End() != (tt.seekForPrev == "") {
+				t.Errorf("SeekForPrev(%q) = %q, want %q", tt.key, got, tt.seekForPrev)
+			}
+		})
+	}
+
+	// 前缀扫描
+	var prefix []string
+	ski := _sl.Iterator()
+	for ski.Seek([]byte("ap")); !ski.End() && bytes.HasPrefix(ski.Key(), []byte("ap")); ski.Next() {
+		prefix = append(prefix, string(ski.Key()))
+	}
+	if strings.Join(prefix, ",") != "apple,apricot" {
+		t.Errorf("prefix scan got %v", prefix)
+	}
+
+	// 从后向前遍历 Prev和Next可以交替
+	var backward []string
+	for ski.Last(); !ski.End(); ski.Prev() {
+		backward = append(backward, string(ski.Value()))
+	}
+	if strings.Join(backward, ",") != "cherry,blueberry,banana,apricot,apple" {
+		t.Errorf("Last()/Prev() got %v", backward)
+	}
+	ski.Prev()
+	ski.Next()
+	if !ski.End() {
+		t.Errorf("Prev()/Next() after End() moved to %q", ski.Key())
+	}
+	ski.Seek([]byte("banana"))
+	ski.Prev()
+	ski.Next()
+	ski.Next()
+	if string(ski.Key()) != "blueberry" {
+		t.Errorf("Prev() then Next() got %q, want %q", ski.Key(), "blueberry")
+	}
+
+	empty := NewSkipListFunc[[]byte, []byte](bytes.Compare).Iterator()
+	if empty.Last(); !empty.End() {
+		t.Errorf("Last() on empty skiplist is not End()")
+	}
+	if empty.SeekForPrev([]byte("z")); !empty.End() {
+		t.Errorf("SeekForPrev() on empty skiplist is not End()")
+	}
+}
+
+// 三种跳表的Range都把nil当作普通的key 不限制上界用RangeFrom 空key可以正常存取
+func TestRange_NilBound(t *testing.T) {
+	sl := NewSkipListFunc[[]byte, []byte](bytes.Compare)
+	csl := NewConcurrentSkipList()
+	asl := NewArenaSkipList(1 << 12)
+	for _, k := range [][]byte{{}, {1}, {2}, {3}} {
+		sl.Put(k, k)
+		csl.Put(k, k)
+		if err := asl.Put(k, k); err != nil {
+			t.Fatalf("Put: %s", err)
+		}
+	}
+
+	type ranger struct {
+		Range     func(lo, hi []byte) iter.Seq2[[]byte, []byte]
+		RangeFrom func(lo []byte) iter.Seq2[[]byte, []byte]
+		All       func() iter.Seq2[[]byte, []byte]
+		Get       func(key []byte) []byte
+	}
+	keys := func(seq iter.Seq2[[]byte, []byte]) (n []int) {
+		for k := range seq {
+			n = append(n, len(k))
+		}
+		return n
+	}
+	for name, r := range map[string]ranger{
+		"SkipList":           {sl.Range, sl.RangeFrom, sl.All, sl.Get},
+		"ConcurrentSkipList": {csl.Range, csl.RangeFrom, csl.All, csl.Get},
+		"ArenaSkipList":      {asl.Range, asl.RangeFrom, asl.All, asl.Get},
+	} {
+		if got := keys(r.Range([]byte{1}, nil)); got != nil {
+			t.Errorf("%s: Range({1}, nil) = %v, want none", name, got)
+		}
+		if got := keys(r.Range(nil, []byte{2})); !slices.Equal(got, []int{0, 1}) {
+			t.Errorf("%s: Range(nil, {2}) lengths = %v, want [0 1]", name, got)
+		}
+		if got := keys(r.RangeFrom([]byte{2})); !slices.Equal(got, []int{1, 1}) {
+			t.Errorf("%s: RangeFrom({2}) lengths = %v, want [1 1]", name, got)
+		}
+		if got := keys(r.All()); len(got) != 4 {
+			t.Errorf("%s: All() returned %d keys, want 4", name, len(got))
+		}
+		if r.Get(nil) == nil || r.Get([]byte{}) == nil {
+			t.Errorf("%s: empty key not found", name)
+		}
+	}
+}
+
 // 前置方法 组装一个SkipList
 func setUpSkipList() {
-	var data = []Node{
+	var data = []Node[[]byte, []byte]{
 		{key: []byte{1}, value: []byte("beijing")},
 		{key: []byte{3}, value: []byte("shanghai")},
 		{key: []byte{9}, value: []byte("tianjin")},
@@ -118,7 +308,7 @@ func setUpSkipList() {
 		{key: []byte{45}, value: []byte("shenyang")},
 	}
 
-	sl = NewSkipList()
+	sl = NewSkipListFunc[[]byte, []byte](bytes.Compare)
 	for _, node := range data {
 		sl.Put(node.key, node.value)
 	}